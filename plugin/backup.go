@@ -0,0 +1,219 @@
+package plugin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+const (
+	// Nanosecond precision keeps backups taken within the same second apart.
+	stateBackupTimeFormat = "20060102T150405.000000000Z"
+
+	// Encryption parameters compatible with
+	// `openssl enc -d -aes-256-cbc -pbkdf2 -iter 100000`.
+	backupSaltHeader = "Salted__"
+	backupSaltSize   = 8
+	backupKeySize    = 32
+	backupIterations = 100000
+)
+
+// StateBackup includes options for state backups.
+type StateBackup struct {
+	Enabled    bool
+	Dir        string
+	Compress   bool
+	Passphrase string
+	Retention  int64
+}
+
+// backupState pulls the current state into a timestamped file in the state backup
+// dir and returns its path. An empty path is returned if there is no state yet.
+func (p *Plugin) backupState(ctx context.Context, action string) (string, error) {
+	backup := p.Settings.StateBackup
+
+	var state bytes.Buffer
+
+	cmd := p.Settings.Tofu.StatePull()
+	cmd.Stdout = &state
+
+	if err := p.runCmd(ctx, cmd); err != nil {
+		return "", fmt.Errorf("failed to backup state: %w", err)
+	}
+
+	if state.Len() == 0 {
+		log.Info().Msg("no state found, skip state backup")
+
+		return "", nil
+	}
+
+	data, ext, err := backup.encode(state.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("failed to backup state: %w", err)
+	}
+
+	if _, ok := workspacePath(backup.Dir); ok && backup.Passphrase == "" {
+		log.Warn().Msgf("state backup dir '%s' is inside the workspace and backups are not encrypted, "+
+			"set state_backup_passphrase to keep the plain state out of caches and artifacts", backup.Dir)
+	}
+
+	if err := os.MkdirAll(backup.Dir, defaultDirPerm); err != nil {
+		return "", fmt.Errorf("failed to create state backup dir: %w", err)
+	}
+
+	path := filepath.Join(
+		backup.Dir,
+		fmt.Sprintf("%s-%s%s", time.Now().UTC().Format(stateBackupTimeFormat), action, ext),
+	)
+
+	if err := os.WriteFile(path, data, defaultFilePerm); err != nil {
+		return "", fmt.Errorf("failed to write state backup: %w", err)
+	}
+
+	log.Info().Msgf("state backup written to '%s'", path)
+
+	if err := backup.prune(); err != nil {
+		log.Warn().Err(err).Msg("failed to remove old state backups")
+	}
+
+	return path, nil
+}
+
+// backupStep wraps the command of a state modifying action with a state backup.
+// If the command fails, the path of the backup is logged for recovery.
+func (p *Plugin) backupStep(action string, cmd *plugin_exec.Cmd) *step {
+	if !p.Settings.StateBackup.Enabled {
+		return &step{cmd: cmd}
+	}
+
+	return &step{
+		run: func(ctx context.Context) error {
+			path, err := p.backupState(ctx, action)
+			if err != nil {
+				return err
+			}
+
+			if err := p.runCmd(ctx, cmd); err != nil {
+				if path != "" {
+					log.Error().Msgf("%s failed, state backup before %s is available at '%s'", action, action, path)
+				}
+
+				return err
+			}
+
+			return nil
+		},
+	}
+}
+
+// encode compresses and encrypts the state as configured and returns the file extension.
+func (b *StateBackup) encode(state []byte) ([]byte, string, error) {
+	ext := ".tfstate"
+
+	if b.Compress {
+		var buf bytes.Buffer
+
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(state); err != nil {
+			return nil, "", err
+		}
+
+		if err := zw.Close(); err != nil {
+			return nil, "", err
+		}
+
+		state = buf.Bytes()
+		ext += ".gz"
+	}
+
+	if b.Passphrase != "" {
+		encrypted, err := encrypt(state, b.Passphrase)
+		if err != nil {
+			return nil, "", err
+		}
+
+		state = encrypted
+		ext += ".enc"
+	}
+
+	return state, ext, nil
+}
+
+// prune removes the oldest state backups exceeding the retention limit.
+func (b *StateBackup) prune() error {
+	if b.Retention <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		return err
+	}
+
+	backupPattern := regexp.MustCompile(`^\d{8}T\d{6}(\.\d+)?Z-.+\.tfstate`)
+	backups := make([]string, 0)
+
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && backupPattern.MatchString(entry.Name()) {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	// Backups are prefixed with a sortable timestamp
+	sort.Strings(backups)
+
+	for len(backups) > int(b.Retention) {
+		if err := os.Remove(filepath.Join(b.Dir, backups[0])); err != nil {
+			return err
+		}
+
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// encrypt encrypts data with AES-256-CBC using an OpenSSL compatible format.
+func encrypt(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, backupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, backupIterations, backupKeySize+aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key[:backupKeySize])
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS#7 padding
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	plain := append(bytes.Clone(data), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	out := make([]byte, 0, len(backupSaltHeader)+backupSaltSize+len(plain))
+	out = append(out, backupSaltHeader...)
+	out = append(out, salt...)
+	out = append(out, make([]byte, len(plain))...)
+
+	cipher.NewCBCEncrypter(block, key[backupKeySize:]).CryptBlocks(out[len(backupSaltHeader)+backupSaltSize:], plain)
+
+	return out, nil
+}
@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decrypt(t *testing.T, data []byte, passphrase string) []byte {
+	t.Helper()
+
+	require.True(t, bytes.HasPrefix(data, []byte(backupSaltHeader)))
+
+	salt := data[len(backupSaltHeader) : len(backupSaltHeader)+backupSaltSize]
+	encrypted := data[len(backupSaltHeader)+backupSaltSize:]
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, backupIterations, backupKeySize+aes.BlockSize)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(key[:backupKeySize])
+	require.NoError(t, err)
+
+	plain := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, key[backupKeySize:]).CryptBlocks(plain, encrypted)
+
+	return plain[:len(plain)-int(plain[len(plain)-1])]
+}
+
+func TestStateBackup_Encode(t *testing.T) {
+	state := []byte(`{"version":4,"serial":1}`)
+
+	tests := []struct {
+		name    string
+		backup  *StateBackup
+		wantExt string
+	}{
+		{
+			name:    "plain backup",
+			backup:  &StateBackup{},
+			wantExt: ".tfstate",
+		},
+		{
+			name:    "compressed backup",
+			backup:  &StateBackup{Compress: true},
+			wantExt: ".tfstate.gz",
+		},
+		{
+			name:    "encrypted backup",
+			backup:  &StateBackup{Passphrase: "secret"},
+			wantExt: ".tfstate.enc",
+		},
+		{
+			name:    "compressed and encrypted backup",
+			backup:  &StateBackup{Compress: true, Passphrase: "secret"},
+			wantExt: ".tfstate.gz.enc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, ext, err := tt.backup.encode(state)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExt, ext)
+
+			if tt.backup.Passphrase != "" {
+				data = decrypt(t, data, tt.backup.Passphrase)
+			}
+
+			if tt.backup.Compress {
+				zr, err := gzip.NewReader(bytes.NewReader(data))
+				require.NoError(t, err)
+
+				data, err = io.ReadAll(zr)
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, state, data)
+		})
+	}
+}
+
+func TestStateBackup_Prune(t *testing.T) {
+	dir := t.TempDir()
+
+	files := []string{
+		"20260101T100000Z-apply.tfstate",
+		"20260102T100000Z-state-mv.tfstate.gz",
+		"20260103T100000Z-destroy.tfstate.gz.enc",
+		"20260104T100000.000000001Z-apply.tfstate",
+		"20260104T100000.000000002Z-destroy.tfstate",
+		"notes.txt",
+	}
+
+	for _, name := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600))
+	}
+
+	backup := &StateBackup{Dir: dir, Retention: 2}
+	require.NoError(t, backup.prune())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	got := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+
+	assert.Equal(t, []string{
+		"20260104T100000.000000001Z-apply.tfstate",
+		"20260104T100000.000000002Z-destroy.tfstate",
+		"notes.txt",
+	}, got)
+}
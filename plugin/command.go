@@ -0,0 +1,83 @@
+package plugin
+
+import "strings"
+
+// mutatingCommands returns the subcommands that modify the state or the infrastructure.
+// They bypass the state backup and lock checks of the dedicated actions and are only
+// permitted by an allowlist entry naming them.
+func mutatingCommands() []string {
+	return []string{
+		"apply",
+		"destroy",
+		"import",
+		"refresh",
+		"test",
+		"taint",
+		"untaint",
+		"force-unlock",
+		"state mv",
+		"state rm",
+		"state push",
+		"state replace-provider",
+		"workspace delete",
+	}
+}
+
+// commandAllowed reports whether the subcommand of args is permitted by the allowlist.
+// An allowlist entry permits a subcommand and all its nested subcommands, e.g. `providers`
+// permits `providers schema -json`. An empty allowlist permits all subcommands that do not
+// modify the state. Subcommands modifying the state must be listed explicitly, e.g. `state`
+// does not permit `state push`. Global options like `-chdir` are not permitted, as they
+// precede the subcommand and would hide it from the allowlist.
+func commandAllowed(args, allowlist []string) bool {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return false
+	}
+
+	subcommand := make([]string, 0)
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+
+		subcommand = append(subcommand, arg)
+	}
+
+	// Minimum number of words of an allowlist entry permitting the subcommand
+	minWords := 0
+
+	for _, mutating := range mutatingCommands() {
+		if words := strings.Fields(mutating); hasWordPrefix(subcommand, words) {
+			minWords = len(words)
+		}
+	}
+
+	if len(allowlist) == 0 {
+		return minWords == 0
+	}
+
+	for _, entry := range allowlist {
+		words := strings.Fields(entry)
+		if len(words) > 0 && len(words) >= minWords && hasWordPrefix(subcommand, words) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasWordPrefix reports whether words starts with all words of prefix.
+func hasWordPrefix(words, prefix []string) bool {
+	if len(prefix) > len(words) {
+		return false
+	}
+
+	for i, word := range prefix {
+		if words[i] != word {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thegeeklab/wp-opentofu/tofu"
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+// dryRunEnvValue is the value of all variables added to the environment in dry run mode.
+const dryRunEnvValue = "<dry-run>"
+
+// setupDryRunEnv adds the variables setupEnv would set to the environment without
+// writing the CLI config, git credentials or SSH key files and without creating the
+// plugin cache dir. Only the names of the variables are printed.
+func (p *Plugin) setupDryRunEnv() {
+	names := make([]string, 0)
+
+	if !p.Settings.CLIConfig.IsEmpty() {
+		names = append(names, tofu.CLIConfigEnv)
+	}
+
+	if p.Settings.Git.SSHKey != "" {
+		names = append(names, "GIT_SSH_COMMAND")
+	}
+
+	if len(p.Settings.Git.Credentials) > 0 {
+		count := p.gitConfigCount()
+		names = append(names, "GIT_CONFIG_COUNT")
+
+		for i := count; i < count+2; i++ {
+			names = append(names, fmt.Sprintf("GIT_CONFIG_KEY_%d", i), fmt.Sprintf("GIT_CONFIG_VALUE_%d", i))
+		}
+	}
+
+	if p.Settings.PluginCacheDir != "" {
+		names = append(names, tofu.PluginCacheDirEnv)
+
+		if p.Settings.PluginCacheMayBreakLockFile {
+			names = append(names, tofu.PluginCacheMayBreakEnv)
+		}
+	}
+
+	for _, name := range names {
+		p.Settings.Tofu.Env = append(p.Settings.Tofu.Env, fmt.Sprintf("%s=%s", name, dryRunEnvValue))
+	}
+}
+
+// printBatch prints the commands of all steps with their working directory and the
+// names of the additional environment variables instead of running them.
+func (p *Plugin) printBatch(batchCmd []*step) {
+	out := p.stdout()
+	n := 0
+
+	for _, s := range batchCmd {
+		for _, cmd := range p.dryRunCmds(s) {
+			p.prepareCmd(cmd)
+
+			n++
+
+			dir := cmd.Dir
+			if dir == "" {
+				dir = "."
+			}
+
+			if abs, err := filepath.Abs(dir); err == nil {
+				dir = abs
+			}
+
+			fmt.Fprintf(out, "[%d] %s\n", n, stepName(s))
+			fmt.Fprintf(out, "    command: %s\n", quoteArgs(cmd.Args))
+			fmt.Fprintf(out, "    dir:     %s\n", dir)
+
+			if names := envNames(cmd.Env); len(names) > 0 {
+				fmt.Fprintf(out, "    env:     %s\n", strings.Join(names, ", "))
+			}
+		}
+	}
+}
+
+// dryRunCmds returns the commands a step runs. The commands of actions that depend
+// on the state, like skipping imports of resources already in the state, are listed
+// as if all of them were run.
+func (p *Plugin) dryRunCmds(s *step) []*plugin_exec.Cmd {
+	cmds := make([]*plugin_exec.Cmd, 0)
+	t := &p.Settings.Tofu
+
+	// State backups taken before state modifying actions
+	switch s.action {
+	case "apply", "apply-refresh-only", "destroy":
+		if p.Settings.StateBackup.Enabled {
+			cmds = append(cmds, t.StatePull())
+		}
+	case "state-mv", "state-rm", "state-push":
+		if !t.StateOptions.DryRun {
+			cmds = append(cmds, t.StatePull())
+		}
+	}
+
+	switch s.action {
+	case "import":
+		cmds = append(cmds, t.StateList())
+
+		for _, target := range t.Imports {
+			cmds = append(cmds, t.Import(target))
+		}
+	case "state-mv":
+		for _, move := range t.StateOptions.Move {
+			cmds = append(cmds, t.StateMove(move))
+		}
+	case "state-rm":
+		cmds = append(cmds, t.StateRemove())
+	case "state-pull":
+		cmds = append(cmds, t.StatePull())
+	case "state-push":
+		if !t.StateOptions.DryRun {
+			cmds = append(cmds, t.StatePush(t.StateOptions.File))
+		}
+	case "force-unlock":
+		cmds = append(cmds, t.LockProbe(), t.ForceUnlock(p.Settings.LockID))
+	case "graph":
+		cmds = append(cmds, t.Graph(p.Settings.GraphType))
+	default:
+		if s.cmd != nil {
+			cmds = append(cmds, s.cmd)
+		}
+	}
+
+	return cmds
+}
+
+// envNames returns the sorted names of all variables in env that are not inherited
+// unchanged from the environment of the plugin.
+func envNames(env []string) []string {
+	inherited := make(map[string]bool)
+	for _, v := range os.Environ() {
+		inherited[v] = true
+	}
+
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, v := range env {
+		name, _, _ := strings.Cut(v, "=")
+		if inherited[v] || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// quoteArgs joins the args and quotes those containing whitespace or quotes.
+func quoteArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\$") {
+			arg = strconv.Quote(arg)
+		}
+
+		quoted = append(quoted, arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
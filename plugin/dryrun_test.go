@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	root := t.TempDir()
+	tmp := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "plugin-cache")
+
+	t.Setenv("TMPDIR", tmp)
+
+	t.Setenv("PLUGIN_ACTION", "validate,plan,state-mv")
+	t.Setenv("PLUGIN_ROOT_DIR", root)
+	t.Setenv("PLUGIN_DRY_RUN", "true")
+	t.Setenv("PLUGIN_REDACT", "false")
+	t.Setenv("PLUGIN_INIT_OPTION", `{"backend-config": ["bucket=my bucket"]}`)
+	t.Setenv("PLUGIN_REGISTRY_CREDENTIALS", `{"app.terraform.io": "registry-token"}`)
+	t.Setenv("PLUGIN_GIT_CREDENTIALS", `{"git.example.com": "git-token"}`)
+	t.Setenv("PLUGIN_SSH_KEY", "ssh-key")
+	t.Setenv("PLUGIN_PLUGIN_CACHE_DIR", cacheDir)
+	t.Setenv("GIT_CONFIG_COUNT", "")
+	t.Setenv("PLUGIN_STATE_OPTION", `{"move": [{"source": "null_resource.a", "destination": "null_resource.b"}]}`)
+
+	p := setupPluginTest(t)
+	require.NoError(t, p.FlagsFromContext())
+	require.NoError(t, p.Validate())
+
+	var out bytes.Buffer
+
+	p.Settings.Tofu.Stdout = &out
+
+	require.NoError(t, p.Execute(t.Context()))
+
+	lines := strings.Split(out.String(), "\n")
+	headers := make([]string, 0)
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "[") {
+			headers = append(headers, line)
+		}
+	}
+
+	assert.Equal(t, []string{
+		"[1] version",
+		"[2] init",
+		"[3] get",
+		"[4] validate",
+		"[5] plan",
+		"[6] state-mv",
+		"[7] state-mv",
+	}, headers)
+
+	assert.Contains(t, out.String(),
+		`    command: /usr/local/bin/tofu init "-backend-config=bucket=my bucket" -input=false`)
+	assert.Contains(t, out.String(), "    command: /usr/local/bin/tofu state pull\n")
+	assert.Contains(t, out.String(), "    command: /usr/local/bin/tofu state mv null_resource.a null_resource.b\n")
+	assert.Contains(t, out.String(), "    dir:     "+root+"\n")
+	assert.Contains(t, out.String(), "    env:     GIT_CONFIG_COUNT, GIT_CONFIG_KEY_0, GIT_CONFIG_KEY_1, "+
+		"GIT_CONFIG_VALUE_0, GIT_CONFIG_VALUE_1, GIT_SSH_COMMAND, TF_CLI_CONFIG_FILE, TF_PLUGIN_CACHE_DIR\n")
+	assert.NotContains(t, out.String(), "registry-token")
+	assert.NotContains(t, out.String(), "git-token")
+
+	// Nothing is written in dry run mode
+	entries, err := os.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.NoDirExists(t, cacheDir)
+}
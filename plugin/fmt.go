@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+// fmtStep runs the fmt command. In check mode, the diff of all unformatted files is
+// collected to print a per-file summary and to write the optional patch file.
+func (p *Plugin) fmtStep() *step {
+	options := p.Settings.Tofu.FmtOptions
+	if options.Check == nil || !*options.Check {
+		return &step{cmd: p.Settings.Tofu.Fmt()}
+	}
+
+	var output bytes.Buffer
+
+	cmd := p.Settings.Tofu.FmtCheck()
+	cmd.Stdout = &output
+
+	return &step{
+		cmd: cmd,
+		finally: func() error {
+			files := tofu.ParseFmtDiff(output.String())
+
+			if options.Diff != nil && *options.Diff {
+				fmt.Fprint(p.stdout(), output.String())
+			}
+
+			for _, file := range files {
+				log.Warn().Msgf("%s is not formatted (+%d -%d)", file.Path, file.Added, file.Removed)
+			}
+
+			if len(files) > 0 {
+				log.Warn().Msgf("%d files are not formatted", len(files))
+			}
+
+			if p.Settings.FmtPatch == "" || len(files) == 0 {
+				return nil
+			}
+
+			// Validation ensures the root dir is inside the workspace
+			root, _ := workspacePath(p.rootDir())
+			patch := tofu.FmtPatch(files, root)
+			if err := os.WriteFile(p.Settings.FmtPatch, []byte(patch), defaultFilePerm); err != nil {
+				return fmt.Errorf("failed to write fmt patch: %w", err)
+			}
+
+			log.Info().Msgf("fmt patch written to '%s', apply it with 'git apply %s'", p.Settings.FmtPatch, p.Settings.FmtPatch)
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultFilePerm = 0o600
+
+	// Default username for HTTPS tokens without an explicit username.
+	defaultGitUsername = "oauth2"
+)
+
+// GitConfig includes options to authenticate git module sources.
+type GitConfig struct {
+	SSHKey        string
+	SSHKnownHosts string
+	Credentials   map[string]string
+}
+
+// IsEmpty reports whether no git authentication option is set.
+func (g *GitConfig) IsEmpty() bool {
+	return g.SSHKey == "" && len(g.Credentials) == 0
+}
+
+// Env writes the SSH key, known hosts and HTTPS credentials into dir and returns the
+// environment variables required to use them for all git commands. The git config
+// entries are added after the configCount entries already present in the environment.
+func (g *GitConfig) Env(dir string, configCount int) ([]string, error) {
+	env := make([]string, 0)
+
+	if g.SSHKey != "" {
+		sshCommand, err := g.writeSSH(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=%s", sshCommand))
+	}
+
+	if len(g.Credentials) > 0 {
+		credentialsFile := filepath.Join(dir, "git-credentials")
+
+		if err := os.WriteFile(credentialsFile, []byte(g.renderCredentials()), defaultFilePerm); err != nil {
+			return nil, fmt.Errorf("failed to write git credentials: %w", err)
+		}
+
+		// Reset inherited helpers before adding the scoped credential store
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_COUNT=%d", configCount+2),
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=credential.helper", configCount),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=", configCount),
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=credential.helper", configCount+1),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=store --file=%s", configCount+1, shellQuote(credentialsFile)),
+		)
+	}
+
+	return env, nil
+}
+
+// writeSSH writes the SSH key and known hosts into dir and returns the SSH command
+// using them. Without known hosts, host keys are verified against the known hosts of
+// the system, so connections to unknown hosts fail.
+func (g *GitConfig) writeSSH(dir string) (string, error) {
+	keyFile := filepath.Join(dir, "id_ssh")
+
+	key := g.SSHKey
+	if !strings.HasSuffix(key, "\n") {
+		key += "\n"
+	}
+
+	if err := os.WriteFile(keyFile, []byte(key), defaultFilePerm); err != nil {
+		return "", fmt.Errorf("failed to write ssh key: %w", err)
+	}
+
+	args := []string{"ssh", "-i", shellQuote(keyFile), "-o", "IdentitiesOnly=yes", "-o", "StrictHostKeyChecking=yes"}
+
+	if g.SSHKnownHosts == "" {
+		log.Info().Msg("no ssh known hosts configured, host keys are verified against the system known hosts")
+
+		return strings.Join(args, " "), nil
+	}
+
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+
+	if err := os.WriteFile(knownHostsFile, []byte(g.SSHKnownHosts), defaultFilePerm); err != nil {
+		return "", fmt.Errorf("failed to write ssh known hosts: %w", err)
+	}
+
+	args = append(args, "-o", shellQuote(fmt.Sprintf("UserKnownHostsFile=%s", knownHostsFile)))
+
+	return strings.Join(args, " "), nil
+}
+
+// gitConfigCount returns the number of git config entries passed in the plugin
+// environment or inherited from the environment of the plugin.
+func (p *Plugin) gitConfigCount() int {
+	value, ok := p.Environment.Lookup("GIT_CONFIG_COUNT")
+	if !ok {
+		value = os.Getenv("GIT_CONFIG_COUNT")
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0
+	}
+
+	return count
+}
+
+// renderCredentials returns the credentials in the format of the git credential store.
+func (g *GitConfig) renderCredentials() string {
+	hosts := make([]string, 0, len(g.Credentials))
+	for host := range g.Credentials {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+
+	var b strings.Builder
+
+	for _, host := range hosts {
+		username, token, ok := strings.Cut(g.Credentials[host], ":")
+		if !ok {
+			username, token = defaultGitUsername, g.Credentials[host]
+		}
+
+		u := url.URL{
+			Scheme: "https",
+			User:   url.UserPassword(username, token),
+			Host:   host,
+		}
+
+		fmt.Fprintln(&b, u.String())
+	}
+
+	return b.String()
+}
+
+// shellQuote quotes s for use as a single word in a shell command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
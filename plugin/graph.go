@@ -0,0 +1,47 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+// runGraph writes the dependency graph in DOT format and optionally renders it as SVG.
+func (p *Plugin) runGraph(ctx context.Context) error {
+	var dot bytes.Buffer
+
+	cmd := p.Settings.Tofu.Graph(p.Settings.GraphType)
+	cmd.Stdout = &dot
+	cmd.Stderr = p.stderr()
+
+	if err := p.runCmd(ctx, cmd); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(p.Settings.GraphFile, dot.Bytes(), defaultFilePerm); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+
+	log.Info().Msgf("%s graph written to '%s'", p.Settings.GraphType, p.Settings.GraphFile)
+
+	if p.Settings.GraphSVGFile == "" {
+		return nil
+	}
+
+	graph, err := tofu.ParseGraph(dot.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to render graph: %w", err)
+	}
+
+	if err := os.WriteFile(p.Settings.GraphSVGFile, graph.SVG(), defaultFilePerm); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+
+	log.Info().Msgf("%s graph rendered to '%s'", p.Settings.GraphType, p.Settings.GraphSVGFile)
+
+	return nil
+}
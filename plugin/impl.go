@@ -1,12 +1,18 @@
 package plugin
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/rs/zerolog/log"
 	"github.com/thegeeklab/wp-opentofu/tofu"
 	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
 )
@@ -17,13 +23,69 @@ var (
 	ErrActionUnknown      = errors.New("action not found")
 	ErrInvalidTofuVersion = errors.New("invalid version string")
 	ErrHTTPError          = errors.New("http error")
+	ErrLockFileChanged    = errors.New("dependency lock file changed")
+	ErrCleanupUnknown     = errors.New("data dir cleanup policy not found")
+	ErrImportInvalid      = errors.New("import requires address and id")
+	ErrStateOptionMissing = errors.New("state option missing")
+	ErrActionOrder        = errors.New("invalid action order")
+	ErrLockIDMissing      = errors.New("lock id missing")
+	ErrLockMismatch       = errors.New("lock id does not match current lock")
+	ErrLockTooRecent      = errors.New("lock is too recent")
+	ErrLockInfoMissing    = errors.New("lock info not found")
+	ErrCommandMissing     = errors.New("command missing")
+	ErrCommandNotAllowed  = errors.New("command not allowed")
+	ErrGraphTypeUnknown   = errors.New("graph type not found")
+	ErrMirrorMissing      = errors.New("provider mirror missing")
+	ErrSeverityUnknown    = errors.New("severity not found")
+	ErrValidateSeverity   = errors.New("validate severity threshold exceeded")
+	ErrActionTimeout      = errors.New("action timeout exceeded")
+	ErrOptionConflict     = errors.New("conflicting options")
 )
 
 const (
 	defaultDirPerm = 0o755
 )
 
+const (
+	DataDirCleanupAlways    = "always"
+	DataDirCleanupOnSuccess = "on-success"
+	DataDirCleanupNever     = "never"
+)
+
+// step is a command of the execution batch.
+type step struct {
+	cmd *plugin_exec.Cmd
+	// action is the name of the action the step belongs to.
+	action string
+	// run replaces cmd for actions that execute multiple commands.
+	run func(ctx context.Context) error
+	// after is called once the command has run successfully.
+	after func() error
+	// finally is called once the command has run, regardless of the result.
+	finally func() error
+	// output is the parsed machine readable output of plan and apply steps.
+	output *tofu.UIOutput
+}
+
 func (p *Plugin) run(ctx context.Context) error {
+	p.Settings.metrics = newRunMetrics()
+
+	err := p.validateAndExecute(ctx)
+
+	// Metrics are written on every exit path, a failed run must not keep reporting
+	// the result of the previous run.
+	if metricsErr := p.writeMetrics(err); metricsErr != nil {
+		if err == nil {
+			return metricsErr
+		}
+
+		log.Error().Err(metricsErr).Msg("metrics not written")
+	}
+
+	return err
+}
+
+func (p *Plugin) validateAndExecute(ctx context.Context) error {
 	if err := p.FlagsFromContext(); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
@@ -32,7 +94,7 @@ func (p *Plugin) run(ctx context.Context) error {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	if err := p.Execute(); err != nil {
+	if err := p.Execute(ctx); err != nil {
 		return fmt.Errorf("execution failed: %w", err)
 	}
 
@@ -58,10 +120,89 @@ func (p *Plugin) FlagsFromContext() error {
 		p.Settings.Tofu.FmtOptions = fmtOptions
 	}
 
+	// Nested modules are formatted unless recursion is disabled explicitly
+	if p.Settings.Tofu.FmtOptions.Recursive == nil {
+		recursive := true
+		p.Settings.Tofu.FmtOptions.Recursive = &recursive
+	}
+
+	if p.App.String("test-option") != "" {
+		testOptions := tofu.TestOptions{}
+		if err := json.Unmarshal([]byte(p.App.String("test-option")), &testOptions); err != nil {
+			return fmt.Errorf("cannot unmarshal test_option: %w", err)
+		}
+
+		p.Settings.Tofu.TestOptions = testOptions
+	}
+
+	if p.App.String("state-option") != "" {
+		stateOptions := tofu.StateOptions{}
+		if err := json.Unmarshal([]byte(p.App.String("state-option")), &stateOptions); err != nil {
+			return fmt.Errorf("cannot unmarshal state_option: %w", err)
+		}
+
+		p.Settings.Tofu.StateOptions = stateOptions
+	}
+
+	if p.App.String("imports") != "" {
+		imports := make([]tofu.ImportTarget, 0)
+		if err := json.Unmarshal([]byte(p.App.String("imports")), &imports); err != nil {
+			return fmt.Errorf("cannot unmarshal imports: %w", err)
+		}
+
+		p.Settings.Tofu.Imports = imports
+	}
+
+	if p.App.String("action-timeout") != "" {
+		timeouts := make(map[string]string)
+		if err := json.Unmarshal([]byte(p.App.String("action-timeout")), &timeouts); err != nil {
+			return fmt.Errorf("cannot unmarshal action_timeout: %w", err)
+		}
+
+		p.Settings.ActionTimeout = make(map[string]time.Duration, len(timeouts))
+
+		for action, value := range timeouts {
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid action_timeout for %s: %w", action, err)
+			}
+
+			p.Settings.ActionTimeout[action] = timeout
+		}
+	}
+
+	if p.App.String("command") != "" {
+		command := make([]string, 0)
+		if err := json.Unmarshal([]byte(p.App.String("command")), &command); err != nil {
+			return fmt.Errorf("cannot unmarshal command: %w", err)
+		}
+
+		p.Settings.Command = command
+	}
+
+	if p.App.String("registry-credentials") != "" {
+		credentials := make(map[string]string)
+		if err := json.Unmarshal([]byte(p.App.String("registry-credentials")), &credentials); err != nil {
+			return fmt.Errorf("cannot unmarshal registry_credentials: %w", err)
+		}
+
+		p.Settings.CLIConfig.Credentials = credentials
+	}
+
+	if p.App.String("git-credentials") != "" {
+		credentials := make(map[string]string)
+		if err := json.Unmarshal([]byte(p.App.String("git-credentials")), &credentials); err != nil {
+			return fmt.Errorf("cannot unmarshal git_credentials: %w", err)
+		}
+
+		p.Settings.Git.Credentials = credentials
+	}
+
 	return nil
 }
 
-// Validate handles the settings validation of the plugin.
+// Validate handles the settings validation of the plugin. All problems found are
+// reported at once.
 func (p *Plugin) Validate() error {
 	p.Settings.DataDir = ".terraform"
 	if value, ok := p.Environment.Lookup("TF_DATA_DIR"); ok {
@@ -73,62 +214,630 @@ func (p *Plugin) Validate() error {
 		p.Settings.Tofu.OutFile = fmt.Sprintf("%s.plan.tfout", p.Settings.DataDir)
 	}
 
+	errs := make([]error, 0)
+
+	for _, addr := range p.Settings.Tofu.Replace {
+		if err := tofu.ValidateResourceAddress(addr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid replace setting: %w", err))
+		}
+	}
+
+	for _, target := range p.Settings.Tofu.Imports {
+		if target.Address == "" || target.ID == "" {
+			errs = append(errs, fmt.Errorf("%w: %s=%s", ErrImportInvalid, target.Address, target.ID))
+		}
+	}
+
+	if p.Settings.CLIConfig.FilesystemMirrorOnly && p.Settings.CLIConfig.FilesystemMirror == "" {
+		errs = append(errs, fmt.Errorf("%w: filesystem_mirror_only requires filesystem_mirror", ErrMirrorMissing))
+	}
+
+	fmtCheck := p.Settings.Tofu.FmtOptions.Check
+	if p.Settings.FmtPatch != "" && (fmtCheck == nil || !*fmtCheck) {
+		errs = append(errs, fmt.Errorf("%w: fmt_patch requires the check option of fmt_option", ErrOptionConflict))
+	}
+
+	if _, ok := workspacePath(p.rootDir()); p.Settings.FmtPatch != "" && !ok {
+		errs = append(errs, fmt.Errorf("%w: fmt_patch requires root_dir inside the workspace", ErrOptionConflict))
+	}
+
+	errs = append(errs, p.validateActions()...)
+
+	switch p.Settings.ValidateSeverity {
+	case tofu.DiagnosticSeverityError, tofu.DiagnosticSeverityWarning:
+	default:
+		errs = append(errs, fmt.Errorf("%w: %s", ErrSeverityUnknown, p.Settings.ValidateSeverity))
+	}
+
+	switch p.Settings.GraphType {
+	case tofu.GraphTypePlan, tofu.GraphTypePlanRefreshOnly, tofu.GraphTypePlanDestroy, tofu.GraphTypeApply:
+	default:
+		errs = append(errs, fmt.Errorf("%w: %s", ErrGraphTypeUnknown, p.Settings.GraphType))
+	}
+
+	switch p.Settings.DataDirCleanup {
+	case DataDirCleanupAlways, DataDirCleanupOnSuccess, DataDirCleanupNever:
+	default:
+		errs = append(errs, fmt.Errorf("%w: %s", ErrCleanupUnknown, p.Settings.DataDirCleanup))
+	}
+
+	return errors.Join(errs...)
+}
+
+// actionSteps returns the step builders of all supported actions. The providers-lock
+// step compares the dependency lock file to lockFile, the content read before init.
+func (p *Plugin) actionSteps(lockFile *string) map[string]func() *step {
+	planStep := func(action string, command func() *plugin_exec.Cmd) func() *step {
+		return func() *step {
+			cmd := command()
+
+			return p.uiStep(action, cmd, &step{cmd: cmd})
+		}
+	}
+
+	applyStep := func(action string, command func() *plugin_exec.Cmd) func() *step {
+		return func() *step {
+			cmd := command()
+
+			return p.uiStep(action, cmd, p.backupStep(action, cmd))
+		}
+	}
+
+	return map[string]func() *step{
+		"fmt":      p.fmtStep,
+		"validate": p.validateStep,
+		"test":     p.testStep,
+		"import":   func() *step { return &step{run: p.runImport} },
+		"plan": planStep("plan", func() *plugin_exec.Cmd {
+			return p.Settings.Tofu.Plan(false)
+		}),
+		"plan-destroy": planStep("plan-destroy", func() *plugin_exec.Cmd {
+			return p.Settings.Tofu.Plan(true)
+		}),
+		"plan-refresh-only":  planStep("plan-refresh-only", p.Settings.Tofu.PlanRefreshOnly),
+		"apply":              applyStep("apply", p.Settings.Tofu.Apply),
+		"apply-refresh-only": applyStep("apply-refresh-only", p.Settings.Tofu.ApplyRefreshOnly),
+		"destroy":            applyStep("destroy", p.Settings.Tofu.Destroy),
+		"providers-lock": func() *step {
+			return &step{
+				cmd: p.Settings.Tofu.ProvidersLock(),
+				after: func() error {
+					return p.checkLockFile(lockFile, false)
+				},
+			}
+		},
+		"providers-mirror": func() *step {
+			return &step{cmd: p.Settings.Tofu.ProvidersMirror(p.Settings.ProvidersMirrorDir)}
+		},
+		"state-list":   func() *step { return &step{cmd: p.Settings.Tofu.StateList()} },
+		"state-mv":     func() *step { return &step{run: p.runStateMove} },
+		"state-rm":     func() *step { return &step{run: p.runStateRemove} },
+		"state-pull":   func() *step { return &step{run: p.runStatePull} },
+		"state-push":   func() *step { return &step{run: p.runStatePush} },
+		"force-unlock": func() *step { return &step{run: p.runForceUnlock} },
+		"graph":        func() *step { return &step{run: p.runGraph} },
+		"command":      func() *step { return &step{cmd: p.Settings.Tofu.Command(p.Settings.Command)} },
+	}
+}
+
+// validateActions checks that all actions are known, that their required options are
+// set and that actions using the saved plan follow a plan action saving a matching plan.
+func (p *Plugin) validateActions() []error {
+	errs := make([]error, 0)
+	steps := p.actionSteps(nil)
+	checks := p.actionOptionChecks()
+
+	// The last plan action, only `plan` and `plan-refresh-only` save a plan
+	lastPlan := ""
+
+	for _, action := range p.Settings.Action {
+		if _, ok := steps[action]; !ok {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrActionUnknown, action))
+
+			continue
+		}
+
+		if err := p.actionOrderError(action, lastPlan); err != nil {
+			errs = append(errs, err)
+		}
+
+		if check, ok := checks[action]; ok {
+			if err := check(action); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if action == "plan" || action == "plan-destroy" || action == "plan-refresh-only" {
+			lastPlan = action
+		}
+	}
+
+	return errs
+}
+
+// actionOrderError returns an error if the action uses a saved plan that was not saved
+// by lastPlan, the last plan action before it.
+func (p *Plugin) actionOrderError(action, lastPlan string) error {
+	switch {
+	case action == "apply" && lastPlan == "plan-destroy":
+		return fmt.Errorf("%w: %s after plan-destroy does not apply the destroy plan, use destroy",
+			ErrActionOrder, action)
+	case action == "apply" && lastPlan == "plan-refresh-only":
+		return fmt.Errorf("%w: %s after plan-refresh-only applies the refresh-only plan, "+
+			"use apply-refresh-only", ErrActionOrder, action)
+	case action == "apply-refresh-only" && lastPlan != "plan-refresh-only":
+		return fmt.Errorf("%w: %s requires plan-refresh-only", ErrActionOrder, action)
+	case action == "graph" && p.Settings.GraphType == tofu.GraphTypeApply &&
+		lastPlan != "plan" && lastPlan != "plan-refresh-only":
+		return fmt.Errorf("%w: %s of type apply requires plan", ErrActionOrder, action)
+	}
+
 	return nil
 }
 
+// actionOptionChecks returns the checks of the options required by an action, keyed
+// by the action.
+func (p *Plugin) actionOptionChecks() map[string]func(action string) error {
+	stateOptions := p.Settings.Tofu.StateOptions
+
+	stateFile := func(action string) error {
+		if stateOptions.File == "" {
+			return fmt.Errorf("%w: %s requires file", ErrStateOptionMissing, action)
+		}
+
+		return nil
+	}
+
+	return map[string]func(action string) error{
+		"command": func(action string) error {
+			if len(p.Settings.Command) == 0 {
+				return fmt.Errorf("%w: %s requires command", ErrCommandMissing, action)
+			}
+
+			if !commandAllowed(p.Settings.Command, p.Settings.CommandAllowlist) {
+				return fmt.Errorf("%w: %s", ErrCommandNotAllowed, strings.Join(p.Settings.Command, " "))
+			}
+
+			return nil
+		},
+		"providers-mirror": func(action string) error {
+			if p.Settings.ProvidersMirrorDir == "" {
+				return fmt.Errorf("%w: %s requires providers_mirror_dir", ErrMirrorMissing, action)
+			}
+
+			return nil
+		},
+		"force-unlock": func(action string) error {
+			if p.Settings.LockID == "" {
+				return fmt.Errorf("%w: %s requires lock_id", ErrLockIDMissing, action)
+			}
+
+			return nil
+		},
+		"state-mv": func(action string) error {
+			if len(stateOptions.Move) == 0 {
+				return fmt.Errorf("%w: %s requires move", ErrStateOptionMissing, action)
+			}
+
+			return nil
+		},
+		"state-rm": func(action string) error {
+			if len(stateOptions.Remove) == 0 {
+				return fmt.Errorf("%w: %s requires remove", ErrStateOptionMissing, action)
+			}
+
+			return nil
+		},
+		"state-pull": stateFile,
+		"state-push": stateFile,
+	}
+}
+
 // Execute provides the implementation of the plugin.
-func (p *Plugin) Execute() error {
-	batchCmd := make([]*plugin_exec.Cmd, 0)
-	batchCmd = append(batchCmd, p.Settings.Tofu.Version())
+func (p *Plugin) Execute(ctx context.Context) error {
+	if p.Settings.metrics == nil {
+		p.Settings.metrics = newRunMetrics()
+	}
+
+	cleanup, err := p.setupEnv()
+	defer cleanup()
 
-	if p.Settings.TofuVersion != "" {
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := p.readLockFile()
+	if err != nil {
+		return err
+	}
+
+	batchCmd := make([]*step, 0)
+	batchCmd = append(batchCmd, &step{cmd: p.Settings.Tofu.Version()})
+
+	if p.Settings.TofuVersion != "" && !p.Settings.DryRun {
 		err := installPackage(p.Network.Context, p.Network.Client, p.Settings.TofuVersion)
 		if err != nil {
 			return err
 		}
 	}
 
-	batchCmd = append(batchCmd, p.Settings.Tofu.Init())
-	batchCmd = append(batchCmd, p.Settings.Tofu.GetModules())
+	batchCmd = append(batchCmd, &step{
+		cmd: p.Settings.Tofu.Init(),
+		after: func() error {
+			return p.checkLockFile(&lockFile, p.Settings.LockFileCheck)
+		},
+	})
+	batchCmd = append(batchCmd, &step{cmd: p.Settings.Tofu.GetModules()})
+
+	steps := p.actionSteps(&lockFile)
 
 	for _, action := range p.Settings.Action {
-		switch action {
-		case "fmt":
-			batchCmd = append(batchCmd, p.Settings.Tofu.Fmt())
-		case "validate":
-			batchCmd = append(batchCmd, p.Settings.Tofu.Validate())
-		case "plan":
-			batchCmd = append(batchCmd, p.Settings.Tofu.Plan(false))
-		case "plan-destroy":
-			batchCmd = append(batchCmd, p.Settings.Tofu.Plan(true))
-		case "apply":
-			batchCmd = append(batchCmd, p.Settings.Tofu.Apply())
-		case "destroy":
-			batchCmd = append(batchCmd, p.Settings.Tofu.Destroy())
-		default:
+		build, ok := steps[action]
+		if !ok {
 			return fmt.Errorf("%w: %s", ErrActionUnknown, action)
 		}
+
+		s := build()
+		s.action = action
+		batchCmd = append(batchCmd, s)
 	}
 
-	if err := os.RemoveAll(p.Settings.DataDir); err != nil {
-		return err
+	if p.Settings.DryRun {
+		p.printBatch(batchCmd)
+		p.flushOutput()
+
+		return nil
 	}
 
-	for _, cmd := range batchCmd {
-		if cmd == nil {
-			continue
+	if p.Settings.DataDirCleanup != DataDirCleanupNever {
+		if err := os.RemoveAll(p.dataDirPath()); err != nil {
+			return err
 		}
+	}
+
+	metrics := p.Settings.metrics
+	runErr := p.runBatch(ctx, batchCmd, metrics)
+
+	metrics.finish(runErr)
+	metrics.printSummary(p.stdout())
+	p.flushOutput()
 
-		if p.Settings.RootDir != "" {
-			cmd.Dir = p.Settings.RootDir
+	if runErr == nil && p.Settings.PluginCacheDir != "" {
+		p.logPluginCacheStats()
+	}
+
+	if p.Settings.DataDirCleanup == DataDirCleanupAlways ||
+		(p.Settings.DataDirCleanup == DataDirCleanupOnSuccess && runErr == nil) {
+		if err := os.RemoveAll(p.dataDirPath()); err != nil && runErr == nil {
+			return err
 		}
+	}
 
-		cmd.Env = append(cmd.Env, p.Environment.Value()...)
+	return runErr
+}
+
+// runBatch runs all commands of the batch in the root dir. Steps of actions with a
+// configured timeout are cancelled once the timeout is exceeded. The duration and
+// result of every step is recorded to metrics.
+func (p *Plugin) runBatch(ctx context.Context, batchCmd []*step, metrics *runMetrics) error {
+	for i, s := range batchCmd {
+		start := time.Now()
+		err := p.runStep(ctx, s)
+		metric := metrics.record(s, start, err)
+
+		if s.finally != nil {
+			if finallyErr := s.finally(); finallyErr != nil {
+				log.Error().Err(finallyErr).Msg("post-processing failed")
+			}
+		}
+
+		if s.output != nil {
+			metric.Changes = s.output.ChangeSummary()
+			metric.Drift = len(s.output.Drift())
+		}
+
+		if err != nil {
+			metrics.skip(batchCmd[i+1:])
+
+			return err
+		}
+
+		if s.after != nil {
+			if err := s.after(); err != nil {
+				metric.Status = StepStatusCheckFailure
+				metrics.skip(batchCmd[i+1:])
+
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// runStep runs a single step with the timeout of its action applied.
+func (p *Plugin) runStep(ctx context.Context, s *step) error {
+	timeout, ok := p.Settings.ActionTimeout[s.action]
+	if !ok || s.action == "" {
+		return p.runStepCmd(ctx, s)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := p.runStepCmd(ctx, s)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s exceeded %s: %w", ErrActionTimeout, s.action, timeout, err)
+	}
+
+	return err
+}
+
+func (p *Plugin) runStepCmd(ctx context.Context, s *step) error {
+	switch {
+	case s.run != nil:
+		return s.run(ctx)
+	case s.cmd != nil:
+		return p.runCmd(ctx, s.cmd)
+	}
+
+	return nil
+}
+
+// prepareCmd applies the root dir and plugin environment to a command.
+func (p *Plugin) prepareCmd(cmd *plugin_exec.Cmd) {
+	if p.Settings.RootDir != "" {
+		cmd.Dir = p.Settings.RootDir
+	}
+
+	cmd.Env = append(cmd.Env, p.Environment.Value()...)
 
-		if err := cmd.Run(); err != nil {
+	// The git config entries extend those of the plugin environment, so the count must follow it
+	cmd.Env = append(cmd.Env, p.Settings.gitEnv...)
+}
+
+// runCmd runs a command in the root dir with the plugin environment and the retry
+// policy applied. If the command fails to acquire the state lock, the lock info is logged.
+// With JSON output, the lock info is reported as diagnostic on stdout.
+func (p *Plugin) runCmd(ctx context.Context, cmd *plugin_exec.Cmd) error {
+	var stderr, diagnostics bytes.Buffer
+
+	p.prepareCmd(cmd)
+
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, &stderr)
+	} else {
+		cmd.Stderr = &stderr
+	}
+
+	diagWriter := tofu.NewDiagnosticWriter(&diagnostics)
+
+	if cmd.Stdout != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, diagWriter)
+	} else {
+		cmd.Stdout = diagWriter
+	}
+
+	err := p.Settings.Tofu.Retry.Run(ctx, cmd, p.Settings.Tofu.GracePeriod)
+
+	diagWriter.Flush()
+	p.flushOutput()
+
+	if err != nil {
+		info, ok := tofu.ParseLockInfo(stderr.String())
+		if !ok {
+			info, ok = tofu.ParseLockInfo(diagnostics.String())
+		}
+
+		if ok {
+			p.logLockInfo(info).Msgf(
+				"state is locked, use the `force-unlock` action with lock_id '%s' to release a stale lock", info.ID,
+			)
+		}
+	}
+
+	return err
+}
+
+// runImport imports all configured resources in order. Resources already present
+// in the state are skipped.
+func (p *Plugin) runImport(ctx context.Context) error {
+	state, err := p.stateAddresses(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list state: %w", err)
+	}
+
+	for _, target := range p.Settings.Tofu.Imports {
+		if _, ok := state[target.Address]; ok {
+			log.Info().Msgf("skip import of '%s', resource already in state", target.Address)
+
+			continue
+		}
+
+		cmd := p.Settings.Tofu.Import(target)
+		if err := p.runCmd(ctx, cmd); err != nil {
 			return err
 		}
 	}
 
-	return os.RemoveAll(p.Settings.DataDir)
+	return nil
+}
+
+// stateAddresses returns the resource addresses of the current state. If no state
+// exists yet, the state is empty.
+func (p *Plugin) stateAddresses(ctx context.Context) (map[string]struct{}, error) {
+	var stderr bytes.Buffer
+
+	cmd := p.Settings.Tofu.StateList()
+	cmd.Stdout = nil
+	cmd.Stderr = &stderr
+	p.prepareCmd(cmd)
+
+	addresses := make(map[string]struct{})
+
+	out, err := tofu.BindContext(ctx, cmd, p.Settings.Tofu.GracePeriod).Output()
+	if err != nil {
+		if tofu.NoStateFound(stderr.String()) {
+			return addresses, nil
+		}
+
+		fmt.Fprint(p.stderr(), stderr.String())
+		p.flushOutput()
+
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			addresses[line] = struct{}{}
+		}
+	}
+
+	return addresses, nil
+}
+
+// testStep creates the step of the test action. If a test report is configured, the
+// machine readable test output is converted into a JUnit report.
+func (p *Plugin) testStep() *step {
+	if p.Settings.TestReport == "" {
+		return &step{cmd: p.Settings.Tofu.Test(false)}
+	}
+
+	cmd := p.Settings.Tofu.Test(true)
+	output := tofu.NewTestOutput(p.stdout())
+	cmd.Stdout = output
+
+	return &step{
+		cmd: cmd,
+		finally: func() error {
+			report := output.Report()
+			p.Settings.redactor.RedactTestReport(report)
+
+			junit, err := report.JUnit()
+			if err != nil {
+				return fmt.Errorf("failed to render test report: %w", err)
+			}
+
+			if err := os.WriteFile(p.Settings.TestReport, junit, defaultFilePerm); err != nil {
+				return fmt.Errorf("failed to write test report: %w", err)
+			}
+
+			log.Info().Msgf("test report written to '%s'", p.Settings.TestReport)
+
+			return nil
+		},
+	}
+}
+
+// setupEnv prepares the additional environment of all tofu commands. The returned
+// cleanup function removes all temporary files and is always safe to call.
+func (p *Plugin) setupEnv() (func(), error) {
+	tmpPaths := make([]string, 0)
+	cleanup := func() {
+		for _, path := range tmpPaths {
+			_ = os.RemoveAll(path)
+		}
+	}
+
+	if p.Settings.Redact {
+		p.setupRedaction()
+	}
+
+	if p.Settings.DryRun {
+		p.setupDryRunEnv()
+
+		return cleanup, nil
+	}
+
+	if !p.Settings.CLIConfig.IsEmpty() {
+		configFile, err := writeCLIConfig(&p.Settings.CLIConfig)
+		if err != nil {
+			return cleanup, err
+		}
+
+		tmpPaths = append(tmpPaths, configFile)
+		p.Settings.Tofu.Env = append(p.Settings.Tofu.Env, fmt.Sprintf("%s=%s", tofu.CLIConfigEnv, configFile))
+	}
+
+	if !p.Settings.Git.IsEmpty() {
+		gitDir, err := os.MkdirTemp("", "git_")
+		if err != nil {
+			return cleanup, fmt.Errorf("failed to create git config dir: %w", err)
+		}
+
+		tmpPaths = append(tmpPaths, gitDir)
+
+		gitEnv, err := p.Settings.Git.Env(gitDir, p.gitConfigCount())
+		if err != nil {
+			return cleanup, err
+		}
+
+		p.Settings.Tofu.Env = append(p.Settings.Tofu.Env, gitEnv...)
+		p.Settings.gitEnv = gitEnv
+	}
+
+	if p.Settings.PluginCacheDir != "" {
+		if err := p.setupPluginCache(); err != nil {
+			return cleanup, err
+		}
+	}
+
+	return cleanup, nil
+}
+
+// setupPluginCache creates the provider plugin cache directory and records the
+// cached provider packages to report cache hits after the run.
+func (p *Plugin) setupPluginCache() error {
+	cacheDir, err := filepath.Abs(p.Settings.PluginCacheDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve plugin cache dir: %w", err)
+	}
+
+	if err := os.MkdirAll(cacheDir, defaultDirPerm); err != nil {
+		return fmt.Errorf("failed to create plugin cache dir: %w", err)
+	}
+
+	p.Settings.PluginCacheDir = cacheDir
+
+	p.Settings.pluginCachePackages, err = tofu.ProviderPackages(cacheDir)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin cache dir: %w", err)
+	}
+
+	p.Settings.Tofu.Env = append(p.Settings.Tofu.Env, fmt.Sprintf("%s=%s", tofu.PluginCacheDirEnv, cacheDir))
+
+	if p.Settings.PluginCacheMayBreakLockFile {
+		log.Warn().Msg("plugin cache may break dependency lock file, " +
+			"checksums for other platforms are not recorded for cached providers")
+
+		p.Settings.Tofu.Env = append(p.Settings.Tofu.Env, fmt.Sprintf("%s=true", tofu.PluginCacheMayBreakEnv))
+	}
+
+	return nil
+}
+
+func (p *Plugin) logPluginCacheStats() {
+	installed, err := tofu.ProviderPackages(filepath.Join(p.dataDirPath(), "providers"))
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to read installed providers")
+
+		return
+	}
+
+	hits, misses := tofu.PluginCacheStats(p.Settings.pluginCachePackages, installed)
+
+	log.Info().
+		Str("dir", p.Settings.PluginCacheDir).
+		Int("hits", hits).
+		Int("misses", misses).
+		Msg("provider plugin cache")
+}
+
+// dataDirPath returns the path of the data dir resolved against the root dir.
+func (p *Plugin) dataDirPath() string {
+	if p.Settings.RootDir == "" || filepath.IsAbs(p.Settings.DataDir) {
+		return p.Settings.DataDir
+	}
+
+	return filepath.Join(p.Settings.RootDir, p.Settings.DataDir)
 }
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+// runForceUnlock releases the configured state lock. To prevent releasing the lock
+// of a running operation, the current lock is checked to match the configured
+// lock ID and to be older than the minimum lock age.
+func (p *Plugin) runForceUnlock(ctx context.Context) error {
+	var stderr bytes.Buffer
+
+	probe := p.Settings.Tofu.LockProbe()
+	p.prepareCmd(probe)
+
+	if probe.Stderr != nil {
+		probe.Stderr = io.MultiWriter(probe.Stderr, &stderr)
+	} else {
+		probe.Stderr = &stderr
+	}
+
+	err := tofu.BindContext(ctx, probe, p.Settings.Tofu.GracePeriod).Run()
+
+	p.flushOutput()
+
+	info, ok := tofu.ParseLockInfo(stderr.String())
+	if !ok {
+		if err == nil || tofu.LockAcquired(stderr.String()) {
+			log.Info().Msg("state is not locked, skip force-unlock")
+
+			return nil
+		}
+
+		return fmt.Errorf("%w: %w", ErrLockInfoMissing, err)
+	}
+
+	p.logLockInfo(info).Msg("state lock found")
+
+	if info.ID != p.Settings.LockID {
+		return fmt.Errorf("%w: %s != %s", ErrLockMismatch, p.Settings.LockID, info.ID)
+	}
+
+	if info.Created.IsZero() {
+		return fmt.Errorf("%w: lock creation time unknown", ErrLockTooRecent)
+	}
+
+	if age := time.Since(info.Created); age < p.Settings.LockMinAge {
+		return fmt.Errorf("%w: lock age %s is below the minimum age of %s",
+			ErrLockTooRecent, age.Round(time.Second), p.Settings.LockMinAge)
+	}
+
+	return p.runCmd(ctx, p.Settings.Tofu.ForceUnlock(info.ID))
+}
+
+// logLockInfo returns a warning event with the redacted lock info fields.
+func (p *Plugin) logLockInfo(info *tofu.LockInfo) *zerolog.Event {
+	return log.Warn().
+		Str("id", info.ID).
+		Str("path", p.redact(info.Path)).
+		Str("operation", p.redact(info.Operation)).
+		Str("who", p.redact(info.Who)).
+		Str("version", info.Version).
+		Time("created", info.Created)
+}
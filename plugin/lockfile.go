@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+// readLockFile returns the content of the dependency lock file in the root dir.
+// A missing lock file is returned as empty content.
+func (p *Plugin) readLockFile() (string, error) {
+	data, err := os.ReadFile(filepath.Join(p.Settings.RootDir, tofu.LockFile))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to read dependency lock file: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// checkLockFile prints the changes of the dependency lock file compared to prev and
+// updates prev to the current content. If enforce is set, any change is an error.
+func (p *Plugin) checkLockFile(prev *string, enforce bool) error {
+	current, err := p.readLockFile()
+	if err != nil {
+		return err
+	}
+
+	if current == *prev {
+		return nil
+	}
+
+	fmt.Fprint(p.stdout(), unifiedDiff(*prev, current, "a/"+tofu.LockFile, "b/"+tofu.LockFile))
+	p.flushOutput()
+
+	*prev = current
+
+	if enforce {
+		return fmt.Errorf("%w: commit the updated %s", ErrLockFileChanged, tofu.LockFile)
+	}
+
+	log.Info().Msgf("dependency lock file '%s' updated", tofu.LockFile)
+
+	return nil
+}
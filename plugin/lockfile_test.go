@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+func TestCheckLockFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		prev    string
+		current string
+		enforce bool
+		wantErr error
+		wantOut string
+	}{
+		{
+			name:    "unchanged lock file",
+			prev:    "provider \"a\" {}\n",
+			current: "provider \"a\" {}\n",
+			enforce: true,
+		},
+		{
+			name:    "changed lock file",
+			prev:    "provider \"a\" {}\n",
+			current: "provider \"b\" {}\n",
+			wantOut: "+provider \"b\" {}",
+		},
+		{
+			name:    "changed lock file enforced",
+			prev:    "",
+			current: "provider \"b\" {}\n",
+			enforce: true,
+			wantErr: ErrLockFileChanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			p := &Plugin{Settings: &Settings{RootDir: t.TempDir()}}
+			p.Settings.Tofu.Stdout = &out
+			require.NoError(t, os.WriteFile(filepath.Join(p.Settings.RootDir, tofu.LockFile), []byte(tt.current), 0o600))
+
+			prev := tt.prev
+			err := p.checkLockFile(&prev, tt.enforce)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.current, prev)
+			assert.Contains(t, out.String(), tt.wantOut)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"text/tabwriter"
+	"time"
+
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+const (
+	StepStatusSuccess = "success"
+	StepStatusFailure = "failure"
+	StepStatusTimeout = "timeout"
+	StepStatusSkipped = "skipped"
+	// StepStatusCheckFailure is the status of a step whose command succeeded but whose
+	// check of the result, like the dependency lock file check, failed.
+	StepStatusCheckFailure = "check-failure"
+)
+
+// stepMetric is the timing and result of a step of the execution batch. Skipped
+// steps have no start time and exit code.
+type stepMetric struct {
+	Name     string        `json:"name"`
+	Start    *time.Time    `json:"start,omitempty"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"duration-seconds"`
+	ExitCode *int          `json:"exit-code,omitempty"`
+	Status   string        `json:"status"`
+	// Changes and Drift are only reported by plan and apply steps with JSON output.
+	Changes *tofu.ChangeSummary `json:"changes,omitempty"`
+	Drift   int                 `json:"drift,omitempty"`
+}
+
+// runMetrics are the timings and results of all steps of a run.
+type runMetrics struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"duration-seconds"`
+	Success  bool          `json:"success"`
+	Steps    []stepMetric  `json:"steps"`
+}
+
+func newRunMetrics() *runMetrics {
+	return &runMetrics{
+		Start: time.Now(),
+		Steps: make([]stepMetric, 0),
+	}
+}
+
+// stepName returns the action of a step or the tofu subcommand of steps that do
+// not belong to an action.
+func stepName(s *step) string {
+	if s.action == "" && s.cmd != nil && len(s.cmd.Args) > 1 {
+		return s.cmd.Args[1]
+	}
+
+	return s.action
+}
+
+// record adds the result of a step that started at start and returned err.
+func (m *runMetrics) record(s *step, start time.Time, err error) *stepMetric {
+	duration := time.Since(start)
+	exitCode := 0
+	metric := stepMetric{
+		Name:     stepName(s),
+		Start:    &start,
+		Duration: duration,
+		Seconds:  duration.Seconds(),
+		ExitCode: &exitCode,
+		Status:   StepStatusSuccess,
+	}
+
+	if err != nil {
+		metric.Status = StepStatusFailure
+		exitCode = -1
+
+		if errors.Is(err, ErrActionTimeout) {
+			metric.Status = StepStatusTimeout
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+
+	m.Steps = append(m.Steps, metric)
+
+	return &m.Steps[len(m.Steps)-1]
+}
+
+// skip adds the steps that were not run after a step failed.
+func (m *runMetrics) skip(steps []*step) {
+	for _, s := range steps {
+		m.Steps = append(m.Steps, stepMetric{
+			Name:   stepName(s),
+			Status: StepStatusSkipped,
+		})
+	}
+}
+
+// finish sets the total duration and result of the run.
+func (m *runMetrics) finish(err error) {
+	m.Duration = time.Since(m.Start)
+	m.Seconds = m.Duration.Seconds()
+	m.Success = err == nil
+}
+
+// printSummary prints a table of the duration and result of all steps.
+func (m *runMetrics) printSummary(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "STEP\tSTATUS\tEXIT\tDURATION")
+
+	for _, s := range m.Steps {
+		if s.ExitCode == nil {
+			fmt.Fprintf(w, "%s\t%s\t\t\n", s.Name, s.Status)
+
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.Name, s.Status, *s.ExitCode, s.Duration.Round(time.Millisecond))
+	}
+
+	status := StepStatusSuccess
+	if !m.Success {
+		status = StepStatusFailure
+	}
+
+	fmt.Fprintf(w, "total\t%s\t\t%s\n", status, m.Duration.Round(time.Millisecond))
+
+	w.Flush()
+}
+
+// writeFile writes the metrics as JSON to path.
+func (m *runMetrics) writeFile(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, defaultFilePerm); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+
+	return nil
+}
+
+// writeMetrics finishes the metrics of a run that returned err and writes them to the
+// configured JSON metrics file and OpenMetrics textfile. Nothing is written in dry run mode.
+func (p *Plugin) writeMetrics(err error) error {
+	m := p.Settings.metrics
+	if m == nil || p.Settings.DryRun {
+		return nil
+	}
+
+	m.finish(err)
+
+	if p.Settings.MetricsFile != "" {
+		if err := m.writeFile(p.Settings.MetricsFile); err != nil {
+			return err
+		}
+	}
+
+	if p.Settings.MetricsTextfile != "" {
+		if err := m.writeOpenMetrics(p.Settings.MetricsTextfile, p.metricLabels()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errStepFailed = errors.New("step failed")
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestRunBatchMetrics(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	require.Error(t, exitErr)
+
+	tests := []struct {
+		name       string
+		steps      []*step
+		wantStatus []string
+		wantExit   []*int
+	}{
+		{
+			name: "all steps succeed",
+			steps: []*step{
+				{action: "validate", run: func(_ context.Context) error { return nil }},
+				{action: "plan", run: func(_ context.Context) error { return nil }},
+			},
+			wantStatus: []string{StepStatusSuccess, StepStatusSuccess},
+			wantExit:   []*int{intPtr(0), intPtr(0)},
+		},
+		{
+			name: "failed command skips remaining steps",
+			steps: []*step{
+				{action: "plan", run: func(_ context.Context) error { return exitErr }},
+				{action: "apply", run: func(_ context.Context) error { return nil }},
+			},
+			wantStatus: []string{StepStatusFailure, StepStatusSkipped},
+			wantExit:   []*int{intPtr(3), nil},
+		},
+		{
+			name: "failed post-processing",
+			steps: []*step{
+				{
+					action: "validate",
+					run:    func(_ context.Context) error { return nil },
+					after:  func() error { return errStepFailed },
+				},
+				{action: "plan", run: func(_ context.Context) error { return nil }},
+			},
+			wantStatus: []string{StepStatusCheckFailure, StepStatusSkipped},
+			wantExit:   []*int{intPtr(0), nil},
+		},
+		{
+			name: "timeout",
+			steps: []*step{
+				{action: "apply", run: func(_ context.Context) error { return ErrActionTimeout }},
+			},
+			wantStatus: []string{StepStatusTimeout},
+			wantExit:   []*int{intPtr(-1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Plugin{Settings: &Settings{}}
+			metrics := newRunMetrics()
+
+			_ = p.runBatch(t.Context(), tt.steps, metrics)
+
+			status := make([]string, 0)
+			exit := make([]*int, 0)
+
+			for _, s := range metrics.Steps {
+				status = append(status, s.Status)
+				exit = append(exit, s.ExitCode)
+			}
+
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantExit, exit)
+		})
+	}
+}
+
+func TestRunMetricsSummary(t *testing.T) {
+	metrics := newRunMetrics()
+	metrics.record(&step{action: "plan"}, metrics.Start, nil)
+	metrics.record(&step{action: "apply"}, metrics.Start, errStepFailed)
+	metrics.skip([]*step{{action: "state-list"}})
+	metrics.finish(errStepFailed)
+
+	var out bytes.Buffer
+
+	metrics.printSummary(&out)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.Equal(t, []string{"STEP", "STATUS", "EXIT", "DURATION"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"plan", StepStatusSuccess, "0"}, strings.Fields(lines[1])[:3])
+	assert.Equal(t, []string{"apply", StepStatusFailure, "-1"}, strings.Fields(lines[2])[:3])
+	assert.Equal(t, []string{"state-list", StepStatusSkipped}, strings.Fields(lines[3]))
+	assert.Equal(t, []string{"total", StepStatusFailure}, strings.Fields(lines[4])[:2])
+
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	require.NoError(t, metrics.writeFile(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var got struct {
+		Success bool `json:"success"`
+		Steps   []struct {
+			Name     string  `json:"name"`
+			Start    *string `json:"start"`
+			Status   string  `json:"status"`
+			ExitCode *int    `json:"exit-code"`
+		} `json:"steps"`
+	}
+
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.False(t, got.Success)
+	require.Len(t, got.Steps, 3)
+	assert.Equal(t, "apply", got.Steps[1].Name)
+	assert.Equal(t, StepStatusFailure, got.Steps[1].Status)
+	assert.NotNil(t, got.Steps[1].Start)
+	assert.Equal(t, intPtr(-1), got.Steps[1].ExitCode)
+	assert.Nil(t, got.Steps[2].Start)
+	assert.Nil(t, got.Steps[2].ExitCode)
+}
+
+func TestRunMetricsValidationFailure(t *testing.T) {
+	dir := t.TempDir()
+	metricsFile := filepath.Join(dir, "metrics.json")
+	textfile := filepath.Join(dir, "opentofu.prom")
+
+	t.Setenv("PLUGIN_ACTION", "unknown")
+	t.Setenv("PLUGIN_METRICS_FILE", metricsFile)
+	t.Setenv("PLUGIN_METRICS_TEXTFILE", textfile)
+	t.Setenv("TF_WORKSPACE", "")
+
+	p := setupPluginTest(t)
+	require.ErrorIs(t, p.run(t.Context()), ErrActionUnknown)
+
+	data, err := os.ReadFile(metricsFile)
+	require.NoError(t, err)
+
+	var got struct {
+		Success bool  `json:"success"`
+		Steps   []any `json:"steps"`
+	}
+
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.False(t, got.Success)
+	assert.Empty(t, got.Steps)
+
+	data, err = os.ReadFile(textfile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `wp_opentofu_run_success{repo="`)
+	assert.Contains(t, string(data), `workspace="default"} 0`+"\n")
+}
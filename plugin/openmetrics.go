@@ -0,0 +1,200 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+const (
+	metricPrefix = "wp_opentofu_"
+
+	// The textfile is read by collectors running as another user.
+	metricsTextfilePerm = 0o644
+)
+
+// metricLabel is a label of an OpenMetrics sample.
+type metricLabel struct {
+	name  string
+	value string
+}
+
+// metricLabels returns the labels identifying the stack of a run: the repository from
+// the CI environment, the root dir and the selected workspace.
+func (p *Plugin) metricLabels() []metricLabel {
+	repo, _ := os.LookupEnv("CI_REPO")
+
+	rootDir := p.Settings.RootDir
+	if rootDir == "" {
+		rootDir = "."
+	}
+
+	workspace, ok := p.Environment.Lookup("TF_WORKSPACE")
+	if !ok {
+		workspace, ok = os.LookupEnv("TF_WORKSPACE")
+	}
+
+	if !ok || workspace == "" {
+		workspace = "default"
+	}
+
+	return []metricLabel{
+		{name: "repo", value: repo},
+		{name: "root_dir", value: rootDir},
+		{name: "workspace", value: workspace},
+	}
+}
+
+// openMetrics renders the metrics in the OpenMetrics text format. The duration is
+// summed up per action, skipped steps are omitted. Resource changes are those of the
+// last step of an action and are only rendered, like drift, if reported by a step.
+// Consecutive plans report the same drift, so the drift is the maximum of all steps.
+func (m *runMetrics) openMetrics(labels []metricLabel) []byte {
+	var buf bytes.Buffer
+
+	success := 0
+	if m.Success {
+		success = 1
+	}
+
+	writeMetricFamily(&buf, "run_success", "Whether the last run succeeded.")
+	writeSample(&buf, "run_success", labels, float64(success))
+
+	writeMetricFamily(&buf, "run_duration_seconds", "Duration of the last run in seconds.")
+	writeSample(&buf, "run_duration_seconds", labels, m.Seconds)
+
+	writeMetricFamily(&buf, "run_timestamp_seconds", "Unix time the last run finished at.")
+	writeSample(&buf, "run_timestamp_seconds", labels, float64(m.Start.Add(m.Duration).UnixMilli())/1000)
+
+	names := make([]string, 0)
+	durations := make(map[string]time.Duration)
+	changes := make(map[string]*tofu.ChangeSummary)
+	drift := -1
+
+	for _, s := range m.Steps {
+		if s.Status == StepStatusSkipped {
+			continue
+		}
+
+		if _, ok := durations[s.Name]; !ok {
+			names = append(names, s.Name)
+		}
+
+		durations[s.Name] += s.Duration
+
+		if s.Changes != nil {
+			changes[s.Name] = s.Changes
+			drift = max(drift, s.Drift)
+		}
+	}
+
+	writeMetricFamily(&buf, "action_duration_seconds", "Duration of the actions of the last run in seconds.")
+
+	for _, name := range names {
+		writeSample(&buf, "action_duration_seconds", withLabels(labels, "action", name), durations[name].Seconds())
+	}
+
+	if drift >= 0 {
+		writeMetricFamily(&buf, "resource_changes", "Resource changes of the plan and apply actions of the last run.")
+
+		for _, name := range names {
+			summary, ok := changes[name]
+			if !ok {
+				continue
+			}
+
+			actionLabels := withLabels(labels, "action", name)
+
+			for _, change := range []struct {
+				name  string
+				count int
+			}{
+				{"add", summary.Add},
+				{"change", summary.Change},
+				{"import", summary.Import},
+				{"remove", summary.Remove},
+			} {
+				writeSample(&buf, "resource_changes", withLabels(actionLabels, "change", change.name), float64(change.count))
+			}
+		}
+
+		detected := 0
+		if drift > 0 {
+			detected = 1
+		}
+
+		writeMetricFamily(&buf, "drift_detected", "Whether resources were changed outside of OpenTofu.")
+		writeSample(&buf, "drift_detected", labels, float64(detected))
+
+		writeMetricFamily(&buf, "drift_resources", "Number of resources changed outside of OpenTofu.")
+		writeSample(&buf, "drift_resources", labels, float64(drift))
+	}
+
+	buf.WriteString("# EOF\n")
+
+	return buf.Bytes()
+}
+
+// writeOpenMetrics writes the metrics in the OpenMetrics text format to path. The file
+// is replaced atomically, so a textfile collector never reads a partially written file.
+func (m *runMetrics) writeOpenMetrics(path string, labels []metricLabel) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(m.openMetrics(labels)); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), metricsTextfilePerm); err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+
+	return nil
+}
+
+func writeMetricFamily(buf *bytes.Buffer, name, help string) {
+	fmt.Fprintf(buf, "# TYPE %s%s gauge\n", metricPrefix, name)
+	fmt.Fprintf(buf, "# HELP %s%s %s\n", metricPrefix, name, help)
+}
+
+func writeSample(buf *bytes.Buffer, name string, labels []metricLabel, value float64) {
+	pairs := make([]string, 0, len(labels))
+
+	for _, label := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", label.name, escapeLabelValue(label.value)))
+	}
+
+	sample := strconv.FormatFloat(value, 'f', -1, 64)
+	fmt.Fprintf(buf, "%s%s{%s} %s\n", metricPrefix, name, strings.Join(pairs, ","), sample)
+}
+
+// withLabels returns a copy of labels with an additional label.
+func withLabels(labels []metricLabel, name, value string) []metricLabel {
+	result := make([]metricLabel, 0, len(labels)+1)
+	result = append(result, labels...)
+
+	return append(result, metricLabel{name: name, value: value})
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+func TestOpenMetrics(t *testing.T) {
+	labels := []metricLabel{
+		{name: "repo", value: "infra/network"},
+		{name: "root_dir", value: `stacks\"prod"`},
+		{name: "workspace", value: "default"},
+	}
+
+	//nolint:lll
+	tests := []struct {
+		name    string
+		metrics *runMetrics
+		want    string
+	}{
+		{
+			name: "plan with drift",
+			metrics: &runMetrics{
+				Start:    time.Unix(1700000000, 0),
+				Duration: 90 * time.Second,
+				Seconds:  90,
+				Success:  true,
+				Steps: []stepMetric{
+					{Name: "init", Duration: 10 * time.Second, Status: StepStatusSuccess},
+					{Name: "plan", Duration: 20 * time.Second, Status: StepStatusSuccess, Drift: 2,
+						Changes: &tofu.ChangeSummary{Add: 1, Change: 3}},
+					{Name: "plan", Duration: 1500 * time.Millisecond, Status: StepStatusSuccess, Drift: 2,
+						Changes: &tofu.ChangeSummary{Remove: 4}},
+				},
+			},
+			want: `# TYPE wp_opentofu_run_success gauge
+# HELP wp_opentofu_run_success Whether the last run succeeded.
+wp_opentofu_run_success{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default"} 1
+# TYPE wp_opentofu_run_duration_seconds gauge
+# HELP wp_opentofu_run_duration_seconds Duration of the last run in seconds.
+wp_opentofu_run_duration_seconds{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default"} 90
+# TYPE wp_opentofu_run_timestamp_seconds gauge
+# HELP wp_opentofu_run_timestamp_seconds Unix time the last run finished at.
+wp_opentofu_run_timestamp_seconds{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default"} 1700000090
+# TYPE wp_opentofu_action_duration_seconds gauge
+# HELP wp_opentofu_action_duration_seconds Duration of the actions of the last run in seconds.
+wp_opentofu_action_duration_seconds{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default",action="init"} 10
+wp_opentofu_action_duration_seconds{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default",action="plan"} 21.5
+# TYPE wp_opentofu_resource_changes gauge
+# HELP wp_opentofu_resource_changes Resource changes of the plan and apply actions of the last run.
+wp_opentofu_resource_changes{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default",action="plan",change="add"} 0
+wp_opentofu_resource_changes{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default",action="plan",change="change"} 0
+wp_opentofu_resource_changes{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default",action="plan",change="import"} 0
+wp_opentofu_resource_changes{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default",action="plan",change="remove"} 4
+# TYPE wp_opentofu_drift_detected gauge
+# HELP wp_opentofu_drift_detected Whether resources were changed outside of OpenTofu.
+wp_opentofu_drift_detected{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default"} 1
+# TYPE wp_opentofu_drift_resources gauge
+# HELP wp_opentofu_drift_resources Number of resources changed outside of OpenTofu.
+wp_opentofu_drift_resources{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default"} 2
+# EOF
+`,
+		},
+		{
+			name: "failed run without JSON output",
+			metrics: &runMetrics{
+				Start:    time.Unix(1700000000, 0),
+				Duration: 5 * time.Second,
+				Seconds:  5,
+				Steps: []stepMetric{
+					{Name: "init", Duration: 5 * time.Second, Status: StepStatusFailure},
+					{Name: "plan", Status: StepStatusSkipped},
+				},
+			},
+			want: `# TYPE wp_opentofu_run_success gauge
+# HELP wp_opentofu_run_success Whether the last run succeeded.
+wp_opentofu_run_success{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default"} 0
+# TYPE wp_opentofu_run_duration_seconds gauge
+# HELP wp_opentofu_run_duration_seconds Duration of the last run in seconds.
+wp_opentofu_run_duration_seconds{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default"} 5
+# TYPE wp_opentofu_run_timestamp_seconds gauge
+# HELP wp_opentofu_run_timestamp_seconds Unix time the last run finished at.
+wp_opentofu_run_timestamp_seconds{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default"} 1700000005
+# TYPE wp_opentofu_action_duration_seconds gauge
+# HELP wp_opentofu_action_duration_seconds Duration of the actions of the last run in seconds.
+wp_opentofu_action_duration_seconds{repo="infra/network",root_dir="stacks\\\"prod\"",workspace="default",action="init"} 5
+# EOF
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(tt.metrics.openMetrics(labels)))
+
+			path := filepath.Join(t.TempDir(), "opentofu.prom")
+			require.NoError(t, tt.metrics.writeOpenMetrics(path, labels))
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(metricsTextfilePerm), info.Mode().Perm())
+
+			entries, err := os.ReadDir(filepath.Dir(path))
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
+
+func TestMetricLabels(t *testing.T) {
+	t.Setenv("CI_REPO", "infra/network")
+	t.Setenv("PLUGIN_ROOT_DIR", "stacks/prod")
+	t.Setenv("PLUGIN_ENVIRONMENT", `{"TF_WORKSPACE": "staging"}`)
+
+	p := setupPluginTest(t)
+	require.NoError(t, p.FlagsFromContext())
+
+	assert.Equal(t, []metricLabel{
+		{name: "repo", value: "infra/network"},
+		{name: "root_dir", value: "stacks/prod"},
+		{name: "workspace", value: "staging"},
+	}, p.metricLabels())
+}
@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/thegeeklab/wp-opentofu/tofu"
 	plugin_base "github.com/thegeeklab/wp-plugin-go/v6/plugin"
@@ -18,11 +19,45 @@ type Plugin struct {
 
 // Settings for the Plugin.
 type Settings struct {
-	Action      []string
-	RootDir     string
-	DataDir     string
-	TofuVersion string
-	Tofu        tofu.Tofu
+	Action         []string
+	RootDir        string
+	DataDir        string
+	DataDirCleanup string
+	ActionTimeout  map[string]time.Duration
+	DryRun         bool
+	TofuVersion    string
+	Tofu           tofu.Tofu
+	CLIConfig      tofu.CLIConfig
+	Git            GitConfig
+
+	LockFileCheck    bool
+	FmtPatch         string
+	Redact           bool
+	RedactPatterns   bool
+	TestReport       string
+	MetricsFile      string
+	MetricsTextfile  string
+	ValidateReport   string
+	ValidateSeverity string
+	StateBackup      StateBackup
+	LockID           string
+	LockMinAge       time.Duration
+
+	Command          []string
+	CommandAllowlist []string
+
+	GraphType    string
+	GraphFile    string
+	GraphSVGFile string
+
+	ProvidersMirrorDir string
+
+	PluginCacheDir              string
+	PluginCacheMayBreakLockFile bool
+	pluginCachePackages         []string
+	gitEnv                      []string
+	redactor                    *tofu.Redactor
+	metrics                     *runMetrics
 }
 
 func New(e plugin_base.ExecuteFunc, build ...string) *Plugin {
@@ -78,6 +113,103 @@ func Flags(settings *Settings, category string) []cli.Flag {
 			Sources:  cli.EnvVars("PLUGIN_FMT_OPTION"),
 			Category: category,
 		},
+		&cli.StringFlag{
+			Name:     "imports",
+			Usage:    "list of resource addresses and IDs imported by the `import` action",
+			Sources:  cli.EnvVars("PLUGIN_IMPORTS"),
+			Category: category,
+		},
+		&cli.StringFlag{
+			Name:     "state-option",
+			Usage:    "options for the `state-*` actions",
+			Sources:  cli.EnvVars("PLUGIN_STATE_OPTION"),
+			Category: category,
+		},
+		&cli.BoolFlag{
+			Name:        "state-backup",
+			Usage:       "take a state backup before `apply` and `destroy` action",
+			Sources:     cli.EnvVars("PLUGIN_STATE_BACKUP"),
+			Destination: &settings.StateBackup.Enabled,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "state-backup-dir",
+			Usage:       "directory of the state backups taken before the state is modified",
+			Sources:     cli.EnvVars("PLUGIN_STATE_BACKUP_DIR"),
+			Value:       "state-backup",
+			Destination: &settings.StateBackup.Dir,
+			Category:    category,
+		},
+		&cli.BoolFlag{
+			Name:        "state-backup-compress",
+			Usage:       "compress state backups with gzip",
+			Sources:     cli.EnvVars("PLUGIN_STATE_BACKUP_COMPRESS"),
+			Destination: &settings.StateBackup.Compress,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "state-backup-passphrase",
+			Usage:       "passphrase to encrypt state backups with AES-256",
+			Sources:     cli.EnvVars("PLUGIN_STATE_BACKUP_PASSPHRASE"),
+			Destination: &settings.StateBackup.Passphrase,
+			Category:    category,
+		},
+		&cli.Int64Flag{
+			Name:        "state-backup-retention",
+			Usage:       "number of state backups to keep, 0 keeps all backups",
+			Sources:     cli.EnvVars("PLUGIN_STATE_BACKUP_RETENTION"),
+			Destination: &settings.StateBackup.Retention,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:     "test-option",
+			Usage:    "options for the test command, see https://opentofu.org/docs/cli/commands/test/",
+			Sources:  cli.EnvVars("PLUGIN_TEST_OPTION"),
+			Category: category,
+		},
+		&cli.StringFlag{
+			Name:        "fmt-patch",
+			Usage:       "file the `fmt` action writes a patch of all unformatted files to in check mode",
+			Sources:     cli.EnvVars("PLUGIN_FMT_PATCH"),
+			Destination: &settings.FmtPatch,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "validate-report",
+			Usage:       "file the `validate` action writes a SARIF report of all diagnostics to",
+			Sources:     cli.EnvVars("PLUGIN_VALIDATE_REPORT"),
+			Destination: &settings.ValidateReport,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "validate-severity",
+			Usage:       "minimum severity of diagnostics failing the `validate` action",
+			Sources:     cli.EnvVars("PLUGIN_VALIDATE_SEVERITY"),
+			Value:       tofu.DiagnosticSeverityError,
+			Destination: &settings.ValidateSeverity,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "test-report",
+			Usage:       "path of the JUnit XML report written by the `test` action",
+			Sources:     cli.EnvVars("PLUGIN_TEST_REPORT"),
+			Destination: &settings.TestReport,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "metrics-file",
+			Usage:       "path of the JSON file the duration and result of all executed steps are written to",
+			Sources:     cli.EnvVars("PLUGIN_METRICS_FILE"),
+			Destination: &settings.MetricsFile,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "metrics-textfile",
+			Usage:       "path of the OpenMetrics text file with the results of the run for a node-exporter textfile collector",
+			Sources:     cli.EnvVars("PLUGIN_METRICS_TEXTFILE"),
+			Destination: &settings.MetricsTextfile,
+			Category:    category,
+		},
 		&cli.Int64Flag{
 			Name:        "parallelism",
 			Usage:       "number of concurrent operations",
@@ -92,6 +224,21 @@ func Flags(settings *Settings, category string) []cli.Flag {
 			Destination: &settings.RootDir,
 			Category:    category,
 		},
+		&cli.StringFlag{
+			Name:        "data-dir-cleanup",
+			Usage:       "when to remove the tofu data dir, one of `always`, `on-success` or `never`",
+			Sources:     cli.EnvVars("PLUGIN_DATA_DIR_CLEANUP"),
+			Value:       DataDirCleanupOnSuccess,
+			Destination: &settings.DataDirCleanup,
+			Category:    category,
+		},
+		&cli.BoolFlag{
+			Name:        "json-output",
+			Usage:       "run `plan`, `apply` and `destroy` actions with machine readable output",
+			Sources:     cli.EnvVars("PLUGIN_JSON_OUTPUT"),
+			Destination: &settings.Tofu.JSONOutput,
+			Category:    category,
+		},
 		&cli.BoolFlag{
 			Name:        "no-log",
 			Usage:       "suppress tofu command output for `plan`, `apply` and `destroy` action",
@@ -99,6 +246,21 @@ func Flags(settings *Settings, category string) []cli.Flag {
 			Destination: &settings.Tofu.NoLog,
 			Category:    category,
 		},
+		&cli.BoolFlag{
+			Name:        "redact",
+			Usage:       "mask secrets and credentials in the tofu command output",
+			Sources:     cli.EnvVars("PLUGIN_REDACT"),
+			Value:       true,
+			Destination: &settings.Redact,
+			Category:    category,
+		},
+		&cli.BoolFlag{
+			Name:        "redact-patterns",
+			Usage:       "mask common credential patterns in addition to secret values if `redact` is enabled",
+			Sources:     cli.EnvVars("PLUGIN_REDACT_PATTERNS"),
+			Destination: &settings.RedactPatterns,
+			Category:    category,
+		},
 		&cli.StringSliceFlag{
 			Name:        "targets",
 			Usage:       "targets to run `plan` or `apply` action on",
@@ -106,6 +268,108 @@ func Flags(settings *Settings, category string) []cli.Flag {
 			Destination: &settings.Tofu.Targets,
 			Category:    category,
 		},
+		&cli.Int64Flag{
+			Name:        "max-retries",
+			Usage:       "number of retries of commands failing due to state lock contention or transient errors",
+			Sources:     cli.EnvVars("PLUGIN_MAX_RETRIES"),
+			Destination: &settings.Tofu.Retry.MaxRetries,
+			Category:    category,
+		},
+		&cli.DurationFlag{
+			Name:        "retry-backoff",
+			Usage:       "initial delay between retries, doubled on every retry",
+			Sources:     cli.EnvVars("PLUGIN_RETRY_BACKOFF"),
+			Value:       10 * time.Second,
+			Destination: &settings.Tofu.Retry.Backoff,
+			Category:    category,
+		},
+		&cli.DurationFlag{
+			Name:        "retry-max-backoff",
+			Usage:       "maximum delay between retries",
+			Sources:     cli.EnvVars("PLUGIN_RETRY_MAX_BACKOFF"),
+			Value:       2 * time.Minute,
+			Destination: &settings.Tofu.Retry.MaxBackoff,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:     "command",
+			Usage:    "tofu subcommand and args executed by the `command` action as JSON array",
+			Sources:  cli.EnvVars("PLUGIN_COMMAND"),
+			Category: category,
+		},
+		&cli.StringSliceFlag{
+			Name:        "command-allowlist",
+			Usage:       "tofu subcommands permitted for the `command` action, state modifying subcommands must be listed",
+			Sources:     cli.EnvVars("PLUGIN_COMMAND_ALLOWLIST"),
+			Destination: &settings.CommandAllowlist,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "graph-type",
+			Usage:       "type of the graph written by the `graph` action",
+			Sources:     cli.EnvVars("PLUGIN_GRAPH_TYPE"),
+			Value:       tofu.GraphTypePlan,
+			Destination: &settings.GraphType,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "graph-file",
+			Usage:       "file the `graph` action writes the DOT output to",
+			Sources:     cli.EnvVars("PLUGIN_GRAPH_FILE"),
+			Value:       "graph.dot",
+			Destination: &settings.GraphFile,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "graph-svg-file",
+			Usage:       "file the `graph` action writes an SVG rendering of the graph to",
+			Sources:     cli.EnvVars("PLUGIN_GRAPH_SVG_FILE"),
+			Destination: &settings.GraphSVGFile,
+			Category:    category,
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "print the commands of all actions without running them",
+			Sources:     cli.EnvVars("PLUGIN_DRY_RUN"),
+			Destination: &settings.DryRun,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:     "action-timeout",
+			Usage:    "timeouts of actions as JSON object mapping action names to durations",
+			Sources:  cli.EnvVars("PLUGIN_ACTION_TIMEOUT"),
+			Category: category,
+		},
+		&cli.DurationFlag{
+			Name:        "grace-period",
+			Usage:       "time a tofu command has to exit after it was interrupted before it is killed",
+			Sources:     cli.EnvVars("PLUGIN_GRACE_PERIOD"),
+			Value:       30 * time.Second,
+			Destination: &settings.Tofu.GracePeriod,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "lock-id",
+			Usage:       "ID of the state lock released by the `force-unlock` action",
+			Sources:     cli.EnvVars("PLUGIN_LOCK_ID"),
+			Destination: &settings.LockID,
+			Category:    category,
+		},
+		&cli.DurationFlag{
+			Name:        "lock-min-age",
+			Usage:       "minimum age of a state lock before it can be released by the `force-unlock` action",
+			Sources:     cli.EnvVars("PLUGIN_LOCK_MIN_AGE"),
+			Value:       time.Hour,
+			Destination: &settings.LockMinAge,
+			Category:    category,
+		},
+		&cli.StringSliceFlag{
+			Name:        "replace",
+			Usage:       "resource addresses to force replacement of in `plan` and `apply` action",
+			Sources:     cli.EnvVars("PLUGIN_REPLACE"),
+			Destination: &settings.Tofu.Replace,
+			Category:    category,
+		},
 		&cli.StringFlag{
 			Name:        "tofu-version",
 			Usage:       "tofu version to use",
@@ -115,11 +379,93 @@ func Flags(settings *Settings, category string) []cli.Flag {
 		},
 		&cli.BoolFlag{
 			Name:        "refresh",
-			Usage:       "enables refreshing of the state before `plan` and `apply` commands",
+			Usage:       "enables refreshing of the state before `plan`, `apply` and `destroy` commands",
 			Sources:     cli.EnvVars("PLUGIN_REFRESH"),
 			Destination: &settings.Tofu.Refresh,
 			Value:       true,
 			Category:    category,
 		},
+		&cli.StringFlag{
+			Name:     "registry-credentials",
+			Usage:    "map of registry hostnames to API tokens written to the tofu CLI configuration",
+			Sources:  cli.EnvVars("PLUGIN_REGISTRY_CREDENTIALS"),
+			Category: category,
+		},
+		&cli.StringFlag{
+			Name:        "network-mirror",
+			Usage:       "URL of a provider network mirror written to the tofu CLI configuration",
+			Sources:     cli.EnvVars("PLUGIN_NETWORK_MIRROR"),
+			Destination: &settings.CLIConfig.NetworkMirror,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "filesystem-mirror",
+			Usage:       "path of a provider filesystem mirror written to the tofu CLI configuration",
+			Sources:     cli.EnvVars("PLUGIN_FILESYSTEM_MIRROR"),
+			Destination: &settings.CLIConfig.FilesystemMirror,
+			Category:    category,
+		},
+		&cli.BoolFlag{
+			Name:        "filesystem-mirror-only",
+			Usage:       "install providers exclusively from the filesystem mirror",
+			Sources:     cli.EnvVars("PLUGIN_FILESYSTEM_MIRROR_ONLY"),
+			Destination: &settings.CLIConfig.FilesystemMirrorOnly,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "providers-mirror-dir",
+			Usage:       "target directory of the `providers-mirror` action",
+			Sources:     cli.EnvVars("PLUGIN_PROVIDERS_MIRROR_DIR"),
+			Destination: &settings.ProvidersMirrorDir,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "plugin-cache-dir",
+			Usage:       "provider plugin cache directory shared across steps",
+			Sources:     cli.EnvVars("PLUGIN_PLUGIN_CACHE_DIR"),
+			Destination: &settings.PluginCacheDir,
+			Category:    category,
+		},
+		&cli.BoolFlag{
+			Name:        "plugin-cache-may-break-dependency-lock-file",
+			Usage:       "use cached providers even if the dependency lock file has no matching checksum",
+			Sources:     cli.EnvVars("PLUGIN_PLUGIN_CACHE_MAY_BREAK_DEPENDENCY_LOCK_FILE"),
+			Destination: &settings.PluginCacheMayBreakLockFile,
+			Category:    category,
+		},
+		&cli.BoolFlag{
+			Name:        "lockfile-check",
+			Usage:       "fail if `init` modifies the dependency lock file",
+			Sources:     cli.EnvVars("PLUGIN_LOCKFILE_CHECK"),
+			Destination: &settings.LockFileCheck,
+			Category:    category,
+		},
+		&cli.StringSliceFlag{
+			Name:        "platforms",
+			Usage:       "target platforms of the `providers-lock` and `providers-mirror` action",
+			Sources:     cli.EnvVars("PLUGIN_PLATFORMS"),
+			Destination: &settings.Tofu.Platforms,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "ssh-key",
+			Usage:       "private ssh key used by git to fetch module sources",
+			Sources:     cli.EnvVars("PLUGIN_SSH_KEY"),
+			Destination: &settings.Git.SSHKey,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:        "ssh-known-hosts",
+			Usage:       "ssh known hosts content used to verify git hosts",
+			Sources:     cli.EnvVars("PLUGIN_SSH_KNOWN_HOSTS"),
+			Destination: &settings.Git.SSHKnownHosts,
+			Category:    category,
+		},
+		&cli.StringFlag{
+			Name:     "git-credentials",
+			Usage:    "map of git hostnames to HTTPS tokens used to fetch module sources",
+			Sources:  cli.EnvVars("PLUGIN_GIT_CREDENTIALS"),
+			Category: category,
+		},
 	}
 }
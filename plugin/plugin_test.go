@@ -4,8 +4,10 @@ import (
 	"context"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/thegeeklab/wp-opentofu/tofu"
 	"github.com/urfave/cli/v3"
 )
@@ -73,7 +75,9 @@ func TestOptionFlags(t *testing.T) {
 				Lock:     boolPtr(false),
 				Lockfile: "test.lock",
 			},
-			wantFmtOptions: tofu.FmtOptions{},
+			wantFmtOptions: tofu.FmtOptions{
+				Recursive: boolPtr(true),
+			},
 		},
 		{
 			name: "fmt options parsing",
@@ -82,9 +86,10 @@ func TestOptionFlags(t *testing.T) {
 			},
 			wantInitOptions: tofu.InitOptions{},
 			wantFmtOptions: tofu.FmtOptions{
-				List:  boolPtr(true),
-				Write: boolPtr(false),
-				Diff:  boolPtr(true),
+				List:      boolPtr(true),
+				Write:     boolPtr(false),
+				Diff:      boolPtr(true),
+				Recursive: boolPtr(true),
 			},
 		},
 		{
@@ -98,8 +103,19 @@ func TestOptionFlags(t *testing.T) {
 				BackendConfig: []string{"config-value"},
 			},
 			wantFmtOptions: tofu.FmtOptions{
-				Check: boolPtr(true),
-				Write: boolPtr(false),
+				Check:     boolPtr(true),
+				Write:     boolPtr(false),
+				Recursive: boolPtr(true),
+			},
+		},
+		{
+			name: "fmt options without recursion",
+			envs: map[string]string{
+				"PLUGIN_FMT_OPTION": `{"recursive":false}`,
+			},
+			wantInitOptions: tofu.InitOptions{},
+			wantFmtOptions: tofu.FmtOptions{
+				Recursive: boolPtr(false),
 			},
 		},
 	}
@@ -119,3 +135,716 @@ func TestOptionFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestRegistryCredentialsFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "registry credentials parsing",
+			envs: map[string]string{
+				"PLUGIN_REGISTRY_CREDENTIALS": `{"app.terraform.io":"token1","registry.example.com":"token2"}`,
+			},
+			want: map[string]string{
+				"app.terraform.io":     "token1",
+				"registry.example.com": "token2",
+			},
+		},
+		{
+			name: "invalid registry credentials",
+			envs: map[string]string{
+				"PLUGIN_REGISTRY_CREDENTIALS": `["token1"]`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			err := got.FlagsFromContext()
+
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.Settings.CLIConfig.Credentials)
+		})
+	}
+}
+
+func TestDataDirCleanupValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "default cleanup policy",
+			envs: map[string]string{},
+			want: DataDirCleanupOnSuccess,
+		},
+		{
+			name: "never cleanup policy",
+			envs: map[string]string{
+				"PLUGIN_DATA_DIR_CLEANUP": "never",
+			},
+			want: DataDirCleanupNever,
+		},
+		{
+			name: "unknown cleanup policy",
+			envs: map[string]string{
+				"PLUGIN_DATA_DIR_CLEANUP": "sometimes",
+			},
+			want:    "sometimes",
+			wantErr: ErrCleanupUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			err := got.Validate()
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got.Settings.DataDirCleanup)
+		})
+	}
+}
+
+func TestDataDirPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *Settings
+		want     string
+	}{
+		{
+			name:     "data dir without root dir",
+			settings: &Settings{DataDir: ".terraform"},
+			want:     ".terraform",
+		},
+		{
+			name:     "data dir with root dir",
+			settings: &Settings{DataDir: ".terraform", RootDir: "infra/prod"},
+			want:     "infra/prod/.terraform",
+		},
+		{
+			name:     "absolute data dir with root dir",
+			settings: &Settings{DataDir: "/tmp/tofu", RootDir: "infra/prod"},
+			want:     "/tmp/tofu",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Plugin{Settings: tt.settings}
+			assert.Equal(t, tt.want, p.dataDirPath())
+		})
+	}
+}
+
+func TestImportsFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		want    []tofu.ImportTarget
+		wantErr error
+	}{
+		{
+			name: "imports parsing",
+			envs: map[string]string{
+				"PLUGIN_IMPORTS": `[{"address":"aws_s3_bucket.logs","id":"logs-bucket"},{"address":"aws_iam_role.ci","id":"ci"}]`,
+			},
+			want: []tofu.ImportTarget{
+				{Address: "aws_s3_bucket.logs", ID: "logs-bucket"},
+				{Address: "aws_iam_role.ci", ID: "ci"},
+			},
+		},
+		{
+			name: "import without id",
+			envs: map[string]string{
+				"PLUGIN_IMPORTS": `[{"address":"aws_s3_bucket.logs"}]`,
+			},
+			want: []tofu.ImportTarget{
+				{Address: "aws_s3_bucket.logs"},
+			},
+			wantErr: ErrImportInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			assert.NoError(t, got.FlagsFromContext())
+			assert.Equal(t, tt.want, got.Settings.Tofu.Imports)
+			assert.ErrorIs(t, got.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestStateOptionValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		wantErr error
+	}{
+		{
+			name: "state mv with moves",
+			envs: map[string]string{
+				"PLUGIN_ACTION":       "state-mv",
+				"PLUGIN_STATE_OPTION": `{"move":[{"source":"aws_instance.a","destination":"aws_instance.b"}]}`,
+			},
+		},
+		{
+			name: "state mv without moves",
+			envs: map[string]string{
+				"PLUGIN_ACTION": "state-mv",
+			},
+			wantErr: ErrStateOptionMissing,
+		},
+		{
+			name: "state rm without addresses",
+			envs: map[string]string{
+				"PLUGIN_ACTION":       "state-rm",
+				"PLUGIN_STATE_OPTION": `{"dry-run":true}`,
+			},
+			wantErr: ErrStateOptionMissing,
+		},
+		{
+			name: "state pull without file",
+			envs: map[string]string{
+				"PLUGIN_ACTION": "state-pull",
+			},
+			wantErr: ErrStateOptionMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			assert.NoError(t, got.FlagsFromContext())
+			assert.ErrorIs(t, got.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestActionOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		wantErr error
+	}{
+		{
+			name: "plan and apply",
+			envs: map[string]string{"PLUGIN_ACTION": "validate,plan,apply"},
+		},
+		{
+			name: "refresh only plan and apply",
+			envs: map[string]string{"PLUGIN_ACTION": "plan-refresh-only,apply-refresh-only"},
+		},
+		{
+			name: "apply without plan",
+			envs: map[string]string{"PLUGIN_ACTION": "apply"},
+		},
+		{
+			name:    "refresh only apply without plan",
+			envs:    map[string]string{"PLUGIN_ACTION": "apply-refresh-only"},
+			wantErr: ErrActionOrder,
+		},
+		{
+			name:    "refresh only apply after plan",
+			envs:    map[string]string{"PLUGIN_ACTION": "plan-refresh-only,plan,apply-refresh-only"},
+			wantErr: ErrActionOrder,
+		},
+		{
+			name:    "apply after destroy plan",
+			envs:    map[string]string{"PLUGIN_ACTION": "plan-destroy,apply"},
+			wantErr: ErrActionOrder,
+		},
+		{
+			name:    "apply after refresh only plan",
+			envs:    map[string]string{"PLUGIN_ACTION": "plan-refresh-only,apply"},
+			wantErr: ErrActionOrder,
+		},
+		{
+			name:    "unknown action",
+			envs:    map[string]string{"PLUGIN_ACTION": "plan,deploy"},
+			wantErr: ErrActionUnknown,
+		},
+		{
+			name:    "fmt patch without check",
+			envs:    map[string]string{"PLUGIN_ACTION": "fmt", "PLUGIN_FMT_PATCH": "fmt.patch"},
+			wantErr: ErrOptionConflict,
+		},
+		{
+			name: "fmt patch with root dir outside of the workspace",
+			envs: map[string]string{
+				"PLUGIN_ACTION":     "fmt",
+				"PLUGIN_FMT_OPTION": `{"check":true}`,
+				"PLUGIN_FMT_PATCH":  "fmt.patch",
+				"PLUGIN_ROOT_DIR":   "../infra",
+			},
+			wantErr: ErrOptionConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			assert.NoError(t, got.FlagsFromContext())
+			assert.ErrorIs(t, got.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	t.Setenv("PLUGIN_ACTION", "deploy,plan-destroy,apply,force-unlock")
+	t.Setenv("PLUGIN_DATA_DIR_CLEANUP", "sometimes")
+
+	got := setupPluginTest(t)
+	assert.NoError(t, got.FlagsFromContext())
+
+	err := got.Validate()
+	assert.ErrorIs(t, err, ErrActionUnknown)
+	assert.ErrorIs(t, err, ErrActionOrder)
+	assert.ErrorIs(t, err, ErrLockIDMissing)
+	assert.ErrorIs(t, err, ErrCleanupUnknown)
+}
+
+func TestReplaceValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace string
+		wantErr error
+	}{
+		{
+			name:    "valid replace addresses",
+			replace: `aws_instance.web[0],module.app.aws_instance.db`,
+		},
+		{
+			name:    "invalid replace address",
+			replace: "module.app",
+			wantErr: tofu.ErrInvalidAddress,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PLUGIN_REPLACE", tt.replace)
+
+			got := setupPluginTest(t)
+			assert.ErrorIs(t, got.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestForceUnlockValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		wantErr error
+	}{
+		{
+			name: "force unlock with lock id",
+			envs: map[string]string{
+				"PLUGIN_ACTION":  "force-unlock",
+				"PLUGIN_LOCK_ID": "0c5f3ed8",
+			},
+		},
+		{
+			name: "force unlock without lock id",
+			envs: map[string]string{
+				"PLUGIN_ACTION": "force-unlock",
+			},
+			wantErr: ErrLockIDMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			assert.ErrorIs(t, got.Validate(), tt.wantErr)
+			assert.Equal(t, time.Hour, got.Settings.LockMinAge)
+		})
+	}
+}
+
+func TestCommandValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		wantErr error
+	}{
+		{
+			name: "command without allowlist",
+			envs: map[string]string{
+				"PLUGIN_ACTION":  "command",
+				"PLUGIN_COMMAND": `["providers", "schema", "-json"]`,
+			},
+		},
+		{
+			name: "command permitted by allowlist",
+			envs: map[string]string{
+				"PLUGIN_ACTION":            "command",
+				"PLUGIN_COMMAND":           `["providers", "schema", "-json"]`,
+				"PLUGIN_COMMAND_ALLOWLIST": "graph,providers schema",
+			},
+		},
+		{
+			name: "command not permitted by allowlist",
+			envs: map[string]string{
+				"PLUGIN_ACTION":            "command",
+				"PLUGIN_COMMAND":           `["providers", "lock"]`,
+				"PLUGIN_COMMAND_ALLOWLIST": "graph,providers schema",
+			},
+			wantErr: ErrCommandNotAllowed,
+		},
+		{
+			name: "flag is not a subcommand",
+			envs: map[string]string{
+				"PLUGIN_ACTION":            "command",
+				"PLUGIN_COMMAND":           `["metadata", "-json"]`,
+				"PLUGIN_COMMAND_ALLOWLIST": "metadata functions",
+			},
+			wantErr: ErrCommandNotAllowed,
+		},
+		{
+			name: "args containing commas",
+			envs: map[string]string{
+				"PLUGIN_ACTION":  "command",
+				"PLUGIN_COMMAND": `["console", "-var=list=[\"a\",\"b\"]"]`,
+			},
+		},
+		{
+			name: "state modifying command without allowlist",
+			envs: map[string]string{
+				"PLUGIN_ACTION":  "command",
+				"PLUGIN_COMMAND": `["apply", "-auto-approve"]`,
+			},
+			wantErr: ErrCommandNotAllowed,
+		},
+		{
+			name: "global option before state modifying command",
+			envs: map[string]string{
+				"PLUGIN_ACTION":  "command",
+				"PLUGIN_COMMAND": `["-chdir=.", "destroy", "-auto-approve"]`,
+			},
+			wantErr: ErrCommandNotAllowed,
+		},
+		{
+			name: "global option before permitted command",
+			envs: map[string]string{
+				"PLUGIN_ACTION":            "command",
+				"PLUGIN_COMMAND":           `["-chdir=.", "providers", "schema", "-json"]`,
+				"PLUGIN_COMMAND_ALLOWLIST": "providers",
+			},
+			wantErr: ErrCommandNotAllowed,
+		},
+		{
+			name: "test command without allowlist",
+			envs: map[string]string{
+				"PLUGIN_ACTION":  "command",
+				"PLUGIN_COMMAND": `["test", "-verbose"]`,
+			},
+			wantErr: ErrCommandNotAllowed,
+		},
+		{
+			name: "test command permitted by allowlist",
+			envs: map[string]string{
+				"PLUGIN_ACTION":            "command",
+				"PLUGIN_COMMAND":           `["test", "-verbose"]`,
+				"PLUGIN_COMMAND_ALLOWLIST": "test",
+			},
+		},
+		{
+			name: "state modifying command permitted by parent entry",
+			envs: map[string]string{
+				"PLUGIN_ACTION":            "command",
+				"PLUGIN_COMMAND":           `["state", "push", "-force", "state.json"]`,
+				"PLUGIN_COMMAND_ALLOWLIST": "state",
+			},
+			wantErr: ErrCommandNotAllowed,
+		},
+		{
+			name: "state modifying command permitted by allowlist",
+			envs: map[string]string{
+				"PLUGIN_ACTION":            "command",
+				"PLUGIN_COMMAND":           `["state", "push", "-force", "state.json"]`,
+				"PLUGIN_COMMAND_ALLOWLIST": "state list,state push",
+			},
+		},
+		{
+			name: "command missing",
+			envs: map[string]string{
+				"PLUGIN_ACTION": "command",
+			},
+			wantErr: ErrCommandMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			assert.NoError(t, got.FlagsFromContext())
+			assert.ErrorIs(t, got.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestGraphValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		wantErr error
+	}{
+		{
+			name: "default plan graph",
+			envs: map[string]string{
+				"PLUGIN_ACTION": "graph",
+			},
+		},
+		{
+			name: "apply graph after plan",
+			envs: map[string]string{
+				"PLUGIN_ACTION":     "plan,graph",
+				"PLUGIN_GRAPH_TYPE": "apply",
+			},
+		},
+		{
+			name: "apply graph after destroy plan",
+			envs: map[string]string{
+				"PLUGIN_ACTION":     "plan-destroy,graph",
+				"PLUGIN_GRAPH_TYPE": "apply",
+			},
+			wantErr: ErrActionOrder,
+		},
+		{
+			name: "apply graph without plan",
+			envs: map[string]string{
+				"PLUGIN_ACTION":     "graph",
+				"PLUGIN_GRAPH_TYPE": "apply",
+			},
+			wantErr: ErrActionOrder,
+		},
+		{
+			name: "unknown graph type",
+			envs: map[string]string{
+				"PLUGIN_ACTION":     "graph",
+				"PLUGIN_GRAPH_TYPE": "validate",
+			},
+			wantErr: ErrGraphTypeUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			assert.ErrorIs(t, got.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestProvidersMirrorValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		wantErr error
+	}{
+		{
+			name: "providers mirror with dir",
+			envs: map[string]string{
+				"PLUGIN_ACTION":               "providers-mirror",
+				"PLUGIN_PROVIDERS_MIRROR_DIR": "/opt/providers",
+			},
+		},
+		{
+			name: "providers mirror without dir",
+			envs: map[string]string{
+				"PLUGIN_ACTION": "providers-mirror",
+			},
+			wantErr: ErrMirrorMissing,
+		},
+		{
+			name: "filesystem mirror only",
+			envs: map[string]string{
+				"PLUGIN_FILESYSTEM_MIRROR":      "/opt/providers",
+				"PLUGIN_FILESYSTEM_MIRROR_ONLY": "true",
+			},
+		},
+		{
+			name: "filesystem mirror only without mirror",
+			envs: map[string]string{
+				"PLUGIN_FILESYSTEM_MIRROR_ONLY": "true",
+			},
+			wantErr: ErrMirrorMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			assert.ErrorIs(t, got.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateSeverityValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "default severity",
+			want: "error",
+		},
+		{
+			name: "warning severity",
+			envs: map[string]string{
+				"PLUGIN_VALIDATE_SEVERITY": "warning",
+			},
+			want: "warning",
+		},
+		{
+			name: "unknown severity",
+			envs: map[string]string{
+				"PLUGIN_VALIDATE_SEVERITY": "info",
+			},
+			want:    "info",
+			wantErr: ErrSeverityUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+			assert.ErrorIs(t, got.Validate(), tt.wantErr)
+			assert.Equal(t, tt.want, got.Settings.ValidateSeverity)
+		})
+	}
+}
+
+func TestActionTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    map[string]string
+		want    map[string]time.Duration
+		wantErr bool
+	}{
+		{
+			name: "action timeout parsing",
+			envs: map[string]string{
+				"PLUGIN_ACTION_TIMEOUT": `{"plan": "30m", "apply": "1h30m"}`,
+			},
+			want: map[string]time.Duration{
+				"plan":  30 * time.Minute,
+				"apply": 90 * time.Minute,
+			},
+		},
+		{
+			name: "invalid action timeout",
+			envs: map[string]string{
+				"PLUGIN_ACTION_TIMEOUT": `{"plan": "soon"}`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := setupPluginTest(t)
+
+			err := got.FlagsFromContext()
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.Settings.ActionTimeout)
+			assert.Equal(t, 30*time.Second, got.Settings.Tofu.GracePeriod)
+		})
+	}
+}
+
+func TestRunStepTimeout(t *testing.T) {
+	p := setupPluginTest(t)
+	p.Settings.ActionTimeout = map[string]time.Duration{"plan": 10 * time.Millisecond}
+
+	wait := func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	err := p.runStep(context.Background(), &step{action: "plan", run: wait})
+	assert.ErrorIs(t, err, ErrActionTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = p.runStep(ctx, &step{action: "apply", run: wait})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrActionTimeout)
+}
+
+func TestCommandFlag(t *testing.T) {
+	t.Setenv("PLUGIN_COMMAND", `["console", "-var=list=[\"a\",\"b\"]"]`)
+
+	got := setupPluginTest(t)
+	require.NoError(t, got.FlagsFromContext())
+	assert.Equal(t, []string{"console", `-var=list=["a","b"]`}, got.Settings.Command)
+}
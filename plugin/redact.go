@@ -0,0 +1,168 @@
+package plugin
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+// setupRedaction redacts the console output of all commands.
+func (p *Plugin) setupRedaction() {
+	redactor := tofu.NewRedactor(p.secrets(), p.Settings.RedactPatterns)
+
+	p.Settings.redactor = redactor
+	p.Settings.Tofu.Stdout = redactor.Writer(os.Stdout)
+	p.Settings.Tofu.Stderr = redactor.Writer(os.Stderr)
+}
+
+// redact masks secrets in text derived from command output if redaction is enabled.
+func (p *Plugin) redact(s string) string {
+	return p.Settings.redactor.Redact(s)
+}
+
+// flushOutput writes buffered partial lines of the redacted console output.
+func (p *Plugin) flushOutput() {
+	for _, w := range []io.Writer{p.Settings.Tofu.Stdout, p.Settings.Tofu.Stderr} {
+		if rw, ok := w.(*tofu.RedactWriter); ok {
+			rw.Flush()
+		}
+	}
+}
+
+// stdout returns the writer of the console output.
+func (p *Plugin) stdout() io.Writer {
+	if p.Settings.Tofu.Stdout != nil {
+		return p.Settings.Tofu.Stdout
+	}
+
+	return os.Stdout
+}
+
+// stderr returns the writer of the console error output.
+func (p *Plugin) stderr() io.Writer {
+	if p.Settings.Tofu.Stderr != nil {
+		return p.Settings.Tofu.Stderr
+	}
+
+	return os.Stderr
+}
+
+// secrets returns the values of all secret settings, sensitive input variables
+// passed as `TF_VAR_*` environment variables and backend config values.
+func (p *Plugin) secrets() []string {
+	secrets := make([]string, 0)
+
+	for _, token := range p.Settings.CLIConfig.Credentials {
+		secrets = append(secrets, token)
+	}
+
+	for _, credential := range p.Settings.Git.Credentials {
+		secrets = append(secrets, credential)
+
+		// Credentials may include a username
+		if _, password, ok := strings.Cut(credential, ":"); ok {
+			secrets = append(secrets, password)
+		}
+	}
+
+	if p.Settings.StateBackup.Passphrase != "" {
+		secrets = append(secrets, p.Settings.StateBackup.Passphrase)
+	}
+
+	for _, config := range p.Settings.Tofu.InitOptions.BackendConfig {
+		// Backend config without a value is a file path
+		if _, value, ok := strings.Cut(config, "="); ok {
+			secrets = append(secrets, value)
+		}
+	}
+
+	names, err := sensitiveVariables(p.rootDir())
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to read sensitive variables")
+	}
+
+	for _, name := range names {
+		key := "TF_VAR_" + name
+
+		if value, ok := p.Environment.Lookup(key); ok {
+			secrets = append(secrets, value)
+		}
+
+		if value, ok := os.LookupEnv(key); ok {
+			secrets = append(secrets, value)
+		}
+	}
+
+	return secrets
+}
+
+// rootDir returns the root dir or the working dir if not set.
+func (p *Plugin) rootDir() string {
+	if p.Settings.RootDir != "" {
+		return p.Settings.RootDir
+	}
+
+	return "."
+}
+
+// sensitiveVariables returns the names of all input variables of the root module in
+// dir that are declared with `sensitive = true`.
+func sensitiveVariables(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	variablePattern := regexp.MustCompile(`(?m)^\s*variable\s+"([^"]+)"\s*\{`)
+	sensitivePattern := regexp.MustCompile(`(?m)^\s*sensitive\s*=\s*true\b`)
+	names := make([]string, 0)
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return names, err
+		}
+
+		src := string(content)
+
+		for _, match := range variablePattern.FindAllStringSubmatchIndex(src, -1) {
+			body := blockBody(src[match[1]:])
+			if sensitivePattern.MatchString(body) {
+				names = append(names, src[match[2]:match[3]])
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// blockBody returns the body of a block up to the closing brace matching the opening
+// brace preceding src. Nested blocks are included.
+func blockBody(src string) string {
+	depth := 1
+	inString := false
+
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return src[:i]
+			}
+		}
+	}
+
+	return src
+}
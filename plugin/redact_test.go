@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thegeeklab/wp-opentofu/tofu"
+)
+
+func TestSensitiveVariables(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "variables.tf"), []byte(`
+variable "region" {
+  default = "eu-central-1"
+}
+
+variable "db_password" {
+  type      = string
+  sensitive = true
+
+  validation {
+    condition     = length(var.db_password) > 8
+    error_message = "Password must be longer than 8 characters { }."
+  }
+}
+
+variable "api_token" {
+  sensitive   = true
+  description = "API token"
+}
+
+variable "insensitive" {
+  sensitive = false
+}
+`), defaultFilePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte(`variable "ignored" { sensitive = true }`),
+		defaultFilePerm))
+
+	got, err := sensitiveVariables(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db_password", "api_token"}, got)
+}
+
+func TestSecrets(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`
+variable "db_password" {
+  sensitive = true
+}
+`), defaultFilePerm))
+
+	t.Setenv("TF_VAR_db_password", "db-s3cr3t")
+
+	p := setupPluginTest(t)
+	p.Settings.RootDir = dir
+	p.Settings.CLIConfig.Credentials = map[string]string{"app.terraform.io": "registry-token"}
+	p.Settings.Git.Credentials = map[string]string{"github.com": "user:git-token"}
+	p.Settings.StateBackup.Passphrase = "backup-passphrase"
+	p.Settings.Tofu.InitOptions = tofu.InitOptions{
+		BackendConfig: []string{"access_key=backend-key", "backend.hcl"},
+	}
+
+	assert.ElementsMatch(t, []string{
+		"registry-token",
+		"user:git-token",
+		"git-token",
+		"backup-passphrase",
+		"backend-key",
+		"db-s3cr3t",
+	}, p.secrets())
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+// runStateMove moves all configured state addresses after taking a state backup.
+func (p *Plugin) runStateMove(ctx context.Context) error {
+	if err := p.backupStateBeforeMutation(ctx, "state-mv"); err != nil {
+		return err
+	}
+
+	for _, move := range p.Settings.Tofu.StateOptions.Move {
+		cmd := p.Settings.Tofu.StateMove(move)
+		if err := p.runCmd(ctx, cmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runStateRemove removes all configured state addresses after taking a state backup.
+func (p *Plugin) runStateRemove(ctx context.Context) error {
+	if err := p.backupStateBeforeMutation(ctx, "state-rm"); err != nil {
+		return err
+	}
+
+	return p.runCmd(ctx, p.Settings.Tofu.StateRemove())
+}
+
+// runStatePull writes the current state to the configured state file.
+func (p *Plugin) runStatePull(ctx context.Context) error {
+	if err := p.pullState(ctx, p.Settings.Tofu.StateOptions.File); err != nil {
+		return err
+	}
+
+	log.Info().Msgf("state written to '%s'", p.Settings.Tofu.StateOptions.File)
+
+	return nil
+}
+
+// runStatePush pushes the configured state file after taking a state backup.
+func (p *Plugin) runStatePush(ctx context.Context) error {
+	path, err := filepath.Abs(p.Settings.Tofu.StateOptions.File)
+	if err != nil {
+		return fmt.Errorf("failed to resolve state file: %w", err)
+	}
+
+	if p.Settings.Tofu.StateOptions.DryRun {
+		log.Info().Msgf("dry run, skip push of state file '%s'", path)
+
+		return nil
+	}
+
+	if err := p.backupStateBeforeMutation(ctx, "state-push"); err != nil {
+		return err
+	}
+
+	return p.runCmd(ctx, p.Settings.Tofu.StatePush(path))
+}
+
+// backupStateBeforeMutation takes a state backup before the state is modified by
+// a state action. No backup is taken in dry run mode.
+func (p *Plugin) backupStateBeforeMutation(ctx context.Context, action string) error {
+	if p.Settings.Tofu.StateOptions.DryRun {
+		return nil
+	}
+
+	_, err := p.backupState(ctx, action)
+
+	return err
+}
+
+// pullState writes the current state to path.
+func (p *Plugin) pullState(ctx context.Context, path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, defaultFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer file.Close()
+
+	cmd := p.Settings.Tofu.StatePull()
+	cmd.Stdout = file
+
+	return p.runCmd(ctx, cmd)
+}
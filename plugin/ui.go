@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/thegeeklab/wp-opentofu/tofu"
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+// uiStep parses the machine readable output of a plan or apply step if JSON output
+// is enabled. The change summary and all failures are logged once the step has run.
+func (p *Plugin) uiStep(action string, cmd *plugin_exec.Cmd, s *step) *step {
+	if !p.Settings.Tofu.JSONOutput {
+		return s
+	}
+
+	out := p.stdout()
+	if p.Settings.Tofu.NoLog {
+		out = io.Discard
+	}
+
+	output := tofu.NewUIOutput(out)
+	cmd.Stdout = output
+	s.output = output
+
+	s.finally = func() error {
+		diags := output.Diagnostics()
+		p.Settings.redactor.RedactDiagnostics(diags)
+		logDiagnostics(diags)
+
+		for _, hook := range output.Failed() {
+			log.Error().
+				Str("resource", hook.Resource.Addr).
+				Str("action", hook.Action).
+				Msgf("%s failed", action)
+		}
+
+		if summary := output.ChangeSummary(); summary != nil {
+			log.Info().
+				Int("add", summary.Add).
+				Int("change", summary.Change).
+				Int("import", summary.Import).
+				Int("remove", summary.Remove).
+				Msgf("%s summary", action)
+		}
+
+		return nil
+	}
+
+	return s
+}
+
+// validateStep runs the validate command with machine readable output, renders the
+// diagnostics and logs them as file annotations. If configured, a SARIF report is written.
+// Warnings fail the step if the severity threshold is set to warning.
+func (p *Plugin) validateStep() *step {
+	var (
+		output bytes.Buffer
+		result *tofu.ValidateResult
+	)
+
+	cmd := p.Settings.Tofu.Validate(true)
+	cmd.Stdout = &output
+
+	return &step{
+		cmd: cmd,
+		finally: func() error {
+			var err error
+
+			result, err = tofu.ParseValidateResult(output.Bytes())
+			if err != nil {
+				return err
+			}
+
+			// The annotations and the report are written without the redacting console writers
+			p.Settings.redactor.RedactDiagnostics(result.Diagnostics)
+
+			for _, diag := range result.Diagnostics {
+				tofu.RenderDiagnostic(p.stdout(), diag)
+			}
+
+			for _, diag := range result.Diagnostics {
+				event := log.Warn()
+				if diag.Severity == tofu.DiagnosticSeverityError {
+					event = log.Error()
+				}
+
+				event.Msg(diag.Annotation(p.Settings.RootDir))
+			}
+
+			log.Info().
+				Bool("valid", result.Valid).
+				Int("errors", result.ErrorCount).
+				Int("warnings", result.WarningCount).
+				Msg("validate summary")
+
+			if p.Settings.ValidateReport == "" {
+				return nil
+			}
+
+			report, err := result.SARIF(p.Settings.RootDir)
+			if err != nil {
+				return fmt.Errorf("failed to render validate report: %w", err)
+			}
+
+			if err := os.WriteFile(p.Settings.ValidateReport, report, defaultFilePerm); err != nil {
+				return fmt.Errorf("failed to write validate report: %w", err)
+			}
+
+			log.Info().Msgf("validate report written to '%s'", p.Settings.ValidateReport)
+
+			return nil
+		},
+		after: func() error {
+			if result != nil && p.Settings.ValidateSeverity == tofu.DiagnosticSeverityWarning && result.WarningCount > 0 {
+				return fmt.Errorf("%w: %d warnings", ErrValidateSeverity, result.WarningCount)
+			}
+
+			return nil
+		},
+	}
+}
+
+// logDiagnostics logs all error diagnostics with their source location.
+func logDiagnostics(diags []*tofu.Diagnostic) {
+	for _, diag := range diags {
+		if diag.Severity != tofu.DiagnosticSeverityError {
+			continue
+		}
+
+		event := log.Error()
+		if diag.Range != nil {
+			event = event.Str("file", fmt.Sprintf("%s:%d", diag.Range.Filename, diag.Range.Start.Line))
+		}
+
+		if diag.Address != "" {
+			event = event.Str("address", diag.Address)
+		}
+
+		event.Msg(diag.Summary)
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"github.com/thegeeklab/wp-opentofu/tofu"
 )
 
+const diffContext = 3
+
 func installPackage(ctx context.Context, client *http.Client, version string) error {
 	// Sanitize user input
 	semverVersion, err := semver.NewVersion(version)
@@ -175,3 +177,155 @@ func sanitizeArchivePath(d, t string) (string, error) {
 
 	return "", fmt.Errorf("%w: %v", ErrTaintedPath, t)
 }
+
+// writeCLIConfig renders the CLI configuration into a temporary file outside of the
+// workspace and returns its path.
+func writeCLIConfig(config *tofu.CLIConfig) (string, error) {
+	file, err := os.CreateTemp("", "tofurc_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create CLI config file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(config.Render()); err != nil {
+		_ = os.Remove(file.Name())
+
+		return "", fmt.Errorf("failed to write CLI config file: %w", err)
+	}
+
+	return file.Name(), nil
+}
+
+// unifiedDiff returns a line based diff of a and b in unified format.
+func unifiedDiff(a, b, fromFile, toFile string) string {
+	if a == b {
+		return ""
+	}
+
+	type diffLine struct {
+		op     byte
+		text   string
+		aIndex int
+		bIndex int
+	}
+
+	aLines, bLines := splitLines(a), splitLines(b)
+
+	// Length of the longest common subsequence of aLines[i:] and bLines[j:]
+	lcs := make([][]int, len(aLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bLines)+1)
+	}
+
+	for i := len(aLines) - 1; i >= 0; i-- {
+		for j := len(bLines) - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]diffLine, 0, len(aLines)+len(bLines))
+
+	for i, j := 0, 0; i < len(aLines) || j < len(bLines); {
+		switch {
+		case i < len(aLines) && j < len(bLines) && aLines[i] == bLines[j]:
+			lines = append(lines, diffLine{' ', aLines[i], i, j})
+			i++
+			j++
+		case i < len(aLines) && (j == len(bLines) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', aLines[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', bLines[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromFile, toFile)
+
+	for start := 0; start < len(lines); {
+		for start < len(lines) && lines[start].op == ' ' {
+			start++
+		}
+
+		if start == len(lines) {
+			break
+		}
+
+		// Merge changes separated by less than two context blocks into one hunk
+		end := start
+		for k := start; k < len(lines); k++ {
+			if lines[k].op != ' ' {
+				end = k + 1
+			} else if k-end+1 > 2*diffContext {
+				break
+			}
+		}
+
+		lo, hi := max(0, start-diffContext), min(len(lines), end+diffContext)
+		aStart, bStart := lines[lo].aIndex+1, lines[lo].bIndex+1
+		aLen, bLen := 0, 0
+
+		for _, line := range lines[lo:hi] {
+			if line.op != '+' {
+				aLen++
+			}
+
+			if line.op != '-' {
+				bLen++
+			}
+		}
+
+		if aLen == 0 {
+			aStart--
+		}
+
+		if bLen == 0 {
+			bStart--
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+
+		for _, line := range lines[lo:hi] {
+			fmt.Fprintf(&out, "%c%s\n", line.op, line.text)
+		}
+
+		start = hi
+	}
+
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// workspacePath returns path relative to the current working directory, the workspace,
+// and reports whether path is inside of it.
+func workspacePath(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return rel, true
+}
@@ -0,0 +1,31 @@
+package tofu
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidAddress = errors.New("invalid resource address")
+
+// ValidateResourceAddress checks if addr is a syntactically valid address of a
+// managed resource or resource instance, e.g. `module.app["a"].aws_instance.web[0]`.
+func ValidateResourceAddress(addr string) error {
+	const (
+		name  = `[A-Za-z_][A-Za-z0-9_-]*`
+		index = `(\[([0-9]+|"([^"\\]|\\.)*")\])?`
+	)
+
+	modulePath := regexp.MustCompile(fmt.Sprintf(`^(module\.%s%s\.)*`, name, index))
+	resource := regexp.MustCompile(fmt.Sprintf(`^%s\.%s%s$`, name, name, index))
+
+	// Strip the module path, the remainder has to be a managed resource
+	rel := modulePath.ReplaceAllString(addr, "")
+
+	if !resource.MatchString(rel) || strings.HasPrefix(rel, "module.") || strings.HasPrefix(rel, "data.") {
+		return fmt.Errorf("%w: %s", ErrInvalidAddress, addr)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package tofu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateResourceAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		wantErr error
+	}{
+		{name: "resource", addr: "aws_instance.web"},
+		{name: "resource with count index", addr: "aws_instance.web[0]"},
+		{name: "resource with key index", addr: `aws_instance.web["primary"]`},
+		{name: "resource in module", addr: "module.app.aws_instance.web"},
+		{name: "resource in nested module instance", addr: `module.app["a"].module.db[1].aws_db_instance.this`},
+		{name: "empty address", addr: "", wantErr: ErrInvalidAddress},
+		{name: "module only", addr: "module.app", wantErr: ErrInvalidAddress},
+		{name: "data resource", addr: "data.aws_ami.ubuntu", wantErr: ErrInvalidAddress},
+		{name: "invalid index", addr: "aws_instance.web[a]", wantErr: ErrInvalidAddress},
+		{name: "flag injection", addr: "-destroy", wantErr: ErrInvalidAddress},
+		{name: "trailing dot", addr: "aws_instance.web.", wantErr: ErrInvalidAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, ValidateResourceAddress(tt.addr), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package tofu
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CLIConfigEnv is the environment variable OpenTofu reads the CLI configuration file from.
+const CLIConfigEnv = "TF_CLI_CONFIG_FILE"
+
+// CLIConfig includes options for the OpenTofu CLI configuration file.
+type CLIConfig struct {
+	Credentials      map[string]string
+	NetworkMirror    string
+	FilesystemMirror string
+	// FilesystemMirrorOnly installs providers exclusively from the filesystem mirror.
+	FilesystemMirrorOnly bool
+}
+
+// IsEmpty reports whether no CLI configuration option is set.
+func (c *CLIConfig) IsEmpty() bool {
+	return len(c.Credentials) == 0 &&
+		c.NetworkMirror == "" &&
+		c.FilesystemMirror == ""
+}
+
+// Render returns the CLI configuration in HCL syntax.
+func (c *CLIConfig) Render() string {
+	var b strings.Builder
+
+	hosts := make([]string, 0, len(c.Credentials))
+	for host := range c.Credentials {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		fmt.Fprintf(&b, "credentials %s {\n", hclString(host))
+		fmt.Fprintf(&b, "  token = %s\n", hclString(c.Credentials[host]))
+		b.WriteString("}\n\n")
+	}
+
+	if c.NetworkMirror != "" || c.FilesystemMirror != "" {
+		b.WriteString("provider_installation {\n")
+
+		if c.NetworkMirror != "" && !c.FilesystemMirrorOnly {
+			b.WriteString("  network_mirror {\n")
+			fmt.Fprintf(&b, "    url = %s\n", hclString(c.NetworkMirror))
+			b.WriteString("  }\n")
+		}
+
+		if c.FilesystemMirror != "" {
+			b.WriteString("  filesystem_mirror {\n")
+			fmt.Fprintf(&b, "    path = %s\n", hclString(c.FilesystemMirror))
+			b.WriteString("  }\n")
+		}
+
+		// Fall back to the origin registries for providers not available in a mirror
+		if !c.FilesystemMirrorOnly {
+			b.WriteString("  direct {}\n")
+		}
+
+		b.WriteString("}\n\n")
+	}
+
+	return b.String()
+}
+
+// hclString returns a quoted HCL string literal with template sequences escaped.
+func hclString(s string) string {
+	s = strconv.Quote(s)
+	s = strings.ReplaceAll(s, "${", "$${")
+	s = strings.ReplaceAll(s, "%{", "%%{")
+
+	return s
+}
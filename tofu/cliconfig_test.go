@@ -0,0 +1,96 @@
+package tofu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCLIConfig_Render(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *CLIConfig
+		want   string
+	}{
+		{
+			name:   "empty config",
+			config: &CLIConfig{},
+			want:   "",
+		},
+		{
+			name: "config with credentials",
+			config: &CLIConfig{
+				Credentials: map[string]string{
+					"registry.example.com": "token2",
+					"app.terraform.io":     "token1",
+				},
+			},
+			want: `credentials "app.terraform.io" {
+  token = "token1"
+}
+
+credentials "registry.example.com" {
+  token = "token2"
+}
+
+`,
+		},
+		{
+			name: "config with mirrors",
+			config: &CLIConfig{
+				NetworkMirror:    "https://mirror.example.com/providers/",
+				FilesystemMirror: "/opt/providers",
+			},
+			want: `provider_installation {
+  network_mirror {
+    url = "https://mirror.example.com/providers/"
+  }
+  filesystem_mirror {
+    path = "/opt/providers"
+  }
+  direct {}
+}
+
+`,
+		},
+		{
+			name: "config with filesystem mirror only",
+			config: &CLIConfig{
+				NetworkMirror:        "https://mirror.example.com/providers/",
+				FilesystemMirror:     "/opt/providers",
+				FilesystemMirrorOnly: true,
+			},
+			want: `provider_installation {
+  filesystem_mirror {
+    path = "/opt/providers"
+  }
+}
+
+`,
+		},
+		{
+			name: "config with template sequences",
+			config: &CLIConfig{
+				Credentials: map[string]string{
+					"app.terraform.io": `to"k${en}`,
+				},
+			},
+			want: `credentials "app.terraform.io" {
+  token = "to\"k$${en}"
+}
+
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.config.Render())
+		})
+	}
+}
+
+func TestCLIConfig_IsEmpty(t *testing.T) {
+	assert.True(t, (&CLIConfig{}).IsEmpty())
+	assert.False(t, (&CLIConfig{NetworkMirror: "https://mirror.example.com"}).IsEmpty())
+}
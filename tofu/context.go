@@ -0,0 +1,32 @@
+package tofu
+
+import (
+	"context"
+	"os"
+	"time"
+
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+// BindContext returns a copy of the command bound to ctx. If ctx is done while the
+// command is running, it is interrupted to let OpenTofu release the state lock and
+// persist the state. If it has not exited after the grace period, it is killed.
+func BindContext(ctx context.Context, cmd *plugin_exec.Cmd, grace time.Duration) *plugin_exec.Cmd {
+	bound := plugin_exec.CommandContext(ctx, cmd.Args[0], cmd.Args[1:]...)
+	bound.Dir = cmd.Dir
+	bound.Env = cmd.Env
+	bound.Stdin = cmd.Stdin
+	bound.Stdout = cmd.Stdout
+	bound.Stderr = cmd.Stderr
+	bound.Trace = cmd.Trace
+	bound.TraceWriter = cmd.TraceWriter
+
+	if grace > 0 {
+		bound.Cancel = func() error {
+			return bound.Process.Signal(os.Interrupt)
+		}
+		bound.WaitDelay = grace
+	}
+
+	return bound
+}
@@ -0,0 +1,53 @@
+package tofu
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+func TestBindContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		grace      time.Duration
+		wantOutput string
+	}{
+		{
+			name:       "interrupt on cancellation",
+			script:     `trap 'echo interrupted; exit 130' INT; echo started; while :; do sleep 0.01; done`,
+			grace:      5 * time.Second,
+			wantOutput: "started\ninterrupted\n",
+		},
+		{
+			name:       "kill after grace period",
+			script:     `trap '' INT; echo started; while :; do sleep 0.01; done`,
+			grace:      100 * time.Millisecond,
+			wantOutput: "started\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			cmd := plugin_exec.Command("sh", "-c", tt.script)
+			cmd.Trace = false
+			cmd.Stdout = &out
+
+			start := time.Now()
+			err := BindContext(ctx, cmd, tt.grace).Run()
+
+			assert.Error(t, err)
+			assert.Less(t, time.Since(start), 3*time.Second)
+			assert.Equal(t, tt.wantOutput, out.String())
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package tofu
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+// Prefixes of the file headers in the diff printed by `tofu fmt -diff`.
+const (
+	fmtDiffOldPrefix = "--- old/"
+	fmtDiffNewPrefix = "+++ new/"
+)
+
+// FmtFile is a file that is not formatted canonically.
+type FmtFile struct {
+	Path    string
+	Diff    string
+	Added   int
+	Removed int
+}
+
+// FmtCheck returns a command that checks the formatting without changing any file and
+// prints the diff of all unformatted files. The command fails if any file is not formatted.
+func (t *Tofu) FmtCheck() *plugin_exec.Cmd {
+	args := []string{
+		"fmt",
+		"-check",
+		"-diff",
+		"-list=false",
+		"-write=false",
+	}
+
+	if t.FmtOptions.Recursive != nil {
+		args = append(args, fmt.Sprintf("-recursive=%t", *t.FmtOptions.Recursive))
+	}
+
+	cmd := t.command(args...)
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+// ParseFmtDiff splits the diff printed by `tofu fmt -diff` into files.
+func ParseFmtDiff(output string) []*FmtFile {
+	files := make([]*FmtFile, 0)
+
+	var (
+		file *FmtFile
+		diff strings.Builder
+	)
+
+	done := func() {
+		if file != nil {
+			file.Diff = diff.String()
+			files = append(files, file)
+		}
+
+		diff.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if line == "" {
+			continue
+		}
+
+		trimmed := strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(trimmed, fmtDiffOldPrefix):
+			done()
+
+			file = &FmtFile{Path: strings.TrimPrefix(trimmed, fmtDiffOldPrefix)}
+		case file == nil:
+			// Skip output before the first diff
+			continue
+		case strings.HasPrefix(trimmed, fmtDiffNewPrefix):
+		case strings.HasPrefix(trimmed, "+"):
+			file.Added++
+		case strings.HasPrefix(trimmed, "-"):
+			file.Removed++
+		}
+
+		diff.WriteString(line)
+
+		if !strings.HasSuffix(line, "\n") {
+			diff.WriteString("\n")
+		}
+	}
+
+	done()
+
+	return files
+}
+
+// FmtPatch returns a patch of all files with their paths prefixed by root. The patch
+// can be applied with `git apply` from the dir root is relative to.
+func FmtPatch(files []*FmtFile, root string) string {
+	var b strings.Builder
+
+	for _, file := range files {
+		name := path.Join(filepath.ToSlash(root), filepath.ToSlash(file.Path))
+
+		for _, line := range strings.SplitAfter(file.Diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, fmtDiffOldPrefix):
+				fmt.Fprintf(&b, "diff --git a/%s b/%s\n", name, name)
+				fmt.Fprintf(&b, "--- a/%s\n", name)
+			case strings.HasPrefix(line, fmtDiffNewPrefix):
+				fmt.Fprintf(&b, "+++ b/%s\n", name)
+			default:
+				b.WriteString(line)
+			}
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package tofu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fmtDiffOutput = `--- old/main.tf
++++ new/main.tf
+@@ -1,3 +1,3 @@
+ resource "null_resource" "a" {
+-  triggers = {foo="bar"}
++  triggers = { foo = "bar" }
+ }
+--- old/modules/db/variables.tf
++++ new/modules/db/variables.tf
+@@ -1,2 +1,3 @@
+-variable "name" {}
++variable "name" {
++}
+ 
+`
+
+func TestTofu_FmtCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		tofu *Tofu
+		want []string
+	}{
+		{
+			name: "fmt check",
+			tofu: &Tofu{},
+			want: []string{TofuBin, "fmt", "-check", "-diff", "-list=false", "-write=false"},
+		},
+		{
+			name: "recursive fmt check",
+			tofu: &Tofu{FmtOptions: FmtOptions{Recursive: boolPtr(true)}},
+			want: []string{TofuBin, "fmt", "-check", "-diff", "-list=false", "-write=false", "-recursive=true"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.FmtCheck()
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestParseFmtDiff(t *testing.T) {
+	files := ParseFmtDiff("Error: something went wrong\n" + fmtDiffOutput)
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "main.tf", files[0].Path)
+	assert.Equal(t, 1, files[0].Added)
+	assert.Equal(t, 1, files[0].Removed)
+	assert.Equal(t, `--- old/main.tf
++++ new/main.tf
+@@ -1,3 +1,3 @@
+ resource "null_resource" "a" {
+-  triggers = {foo="bar"}
++  triggers = { foo = "bar" }
+ }
+`, files[0].Diff)
+
+	assert.Equal(t, "modules/db/variables.tf", files[1].Path)
+	assert.Equal(t, 2, files[1].Added)
+	assert.Equal(t, 1, files[1].Removed)
+
+	assert.Empty(t, ParseFmtDiff(""))
+}
+
+func TestFmtPatch(t *testing.T) {
+	files := ParseFmtDiff(fmtDiffOutput)
+
+	assert.Equal(t, `diff --git a/infra/main.tf b/infra/main.tf
+--- a/infra/main.tf
++++ b/infra/main.tf
+@@ -1,3 +1,3 @@
+ resource "null_resource" "a" {
+-  triggers = {foo="bar"}
++  triggers = { foo = "bar" }
+ }
+diff --git a/infra/modules/db/variables.tf b/infra/modules/db/variables.tf
+--- a/infra/modules/db/variables.tf
++++ b/infra/modules/db/variables.tf
+@@ -1,2 +1,3 @@
+-variable "name" {}
++variable "name" {
++}
+ 
+`, FmtPatch(files, "infra"))
+}
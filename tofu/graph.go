@@ -0,0 +1,447 @@
+package tofu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+const (
+	GraphTypePlan            = "plan"
+	GraphTypePlanRefreshOnly = "plan-refresh-only"
+	GraphTypePlanDestroy     = "plan-destroy"
+	GraphTypeApply           = "apply"
+)
+
+// SVG layout parameters.
+const (
+	graphNodeHeight   = 30
+	graphNodePadding  = 20
+	graphCharWidth    = 7
+	graphNodeGap      = 20
+	graphLayerGap     = 60
+	graphMargin       = 20
+	graphFontSize     = 12
+	graphMaxLayerScan = 2
+)
+
+var ErrGraphSyntax = errors.New("invalid graph syntax")
+
+// DependencyGraph is a directed graph parsed from DOT output.
+type DependencyGraph struct {
+	Nodes []*GraphNode
+	Edges []*GraphEdge
+}
+
+// GraphNode is a node of a dependency graph.
+type GraphNode struct {
+	ID    string
+	Label string
+}
+
+// GraphEdge points from a node to one of its dependencies.
+type GraphEdge struct {
+	From string
+	To   string
+}
+
+// Graph returns a command printing the dependency graph in DOT format. The apply
+// graph is rendered from the saved plan file.
+func (t *Tofu) Graph(graphType string) *plugin_exec.Cmd {
+	args := []string{
+		"graph",
+	}
+
+	if graphType == GraphTypeApply {
+		args = append(args, fmt.Sprintf("-plan=%s", t.OutFile))
+	} else {
+		args = append(args, fmt.Sprintf("-type=%s", graphType))
+	}
+
+	return t.command(args...)
+}
+
+// ParseGraph parses the nodes and edges of a graph in DOT format. Attributes other
+// than node labels as well as subgraph structure are ignored.
+func ParseGraph(dot []byte) (*DependencyGraph, error) {
+	tokens, err := tokenizeDOT(string(dot))
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &DependencyGraph{}
+	nodes := make(map[string]*GraphNode)
+
+	node := func(id string) *GraphNode {
+		if n, ok := nodes[id]; ok {
+			return n
+		}
+
+		n := &GraphNode{ID: id, Label: id}
+		nodes[id] = n
+		graph.Nodes = append(graph.Nodes, n)
+
+		return n
+	}
+
+	for i := 0; i < len(tokens); {
+		tok := tokens[i]
+
+		switch {
+		case tok.punct("{"), tok.punct("}"), tok.punct(";"):
+			i++
+		case tok.keyword("strict"), tok.keyword("digraph"), tok.keyword("graph") && !tokens.at(i+1).punct("["),
+			tok.keyword("subgraph"):
+			i++
+			// Skip the optional name of the graph
+			if next := tokens.at(i); next.id() {
+				i++
+			}
+		case (tok.keyword("graph") || tok.keyword("node") || tok.keyword("edge")) && tokens.at(i+1).punct("["):
+			_, i, err = tokens.attrs(i + 1)
+		case tok.id() && tokens.at(i+1).punct("="):
+			// Graph attribute
+			i += 3
+		case tok.id():
+			ids := []string{tok.value}
+			i++
+
+			for tokens.at(i).punct("->") || tokens.at(i).punct("--") {
+				if !tokens.at(i + 1).id() {
+					return nil, fmt.Errorf("%w: edge without target", ErrGraphSyntax)
+				}
+
+				ids = append(ids, tokens.at(i+1).value)
+				i += 2
+			}
+
+			var attrs map[string]string
+
+			if tokens.at(i).punct("[") {
+				attrs, i, err = tokens.attrs(i)
+			}
+
+			for _, id := range ids {
+				node(id)
+			}
+
+			if len(ids) == 1 {
+				if label, ok := attrs["label"]; ok && label != "" {
+					nodes[ids[0]].Label = label
+				}
+			}
+
+			for j := 1; j < len(ids); j++ {
+				graph.Edges = append(graph.Edges, &GraphEdge{From: ids[j-1], To: ids[j]})
+			}
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q", ErrGraphSyntax, tok.value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return graph, nil
+}
+
+// SVG renders the graph with a layered layout. Nodes are placed above their
+// dependencies and edges are drawn as straight arrows.
+func (g *DependencyGraph) SVG() []byte {
+	layers := g.layers()
+
+	width := 0
+	rowWidths := make([]int, len(layers))
+
+	for i, layer := range layers {
+		for j, n := range layer {
+			if j > 0 {
+				rowWidths[i] += graphNodeGap
+			}
+
+			rowWidths[i] += nodeWidth(n)
+		}
+
+		width = max(width, rowWidths[i])
+	}
+
+	type box struct{ x, y, w int }
+
+	boxes := make(map[string]box)
+
+	for i, layer := range layers {
+		x := graphMargin + (width-rowWidths[i])/2
+		y := graphMargin + i*(graphNodeHeight+graphLayerGap)
+
+		for _, n := range layer {
+			boxes[n.ID] = box{x: x, y: y, w: nodeWidth(n)}
+			x += nodeWidth(n) + graphNodeGap
+		}
+	}
+
+	totalWidth := width + 2*graphMargin
+	totalHeight := 2*graphMargin + len(layers)*graphNodeHeight + max(len(layers)-1, 0)*graphLayerGap
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		totalWidth, totalHeight, totalWidth, totalHeight)
+	buf.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" ` +
+		`markerWidth="8" markerHeight="8" orient="auto-start-reverse">` +
+		`<path d="M 0 0 L 10 5 L 0 10 z" fill="#555"/></marker></defs>` + "\n")
+
+	for _, e := range g.Edges {
+		from, to := boxes[e.From], boxes[e.To]
+
+		fmt.Fprintf(&buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#555" marker-end="url(#arrow)"/>`+"\n",
+			from.x+from.w/2, from.y+graphNodeHeight, to.x+to.w/2, to.y)
+	}
+
+	for _, layer := range layers {
+		for _, n := range layer {
+			b := boxes[n.ID]
+
+			fmt.Fprintf(&buf, `<g><title>%s</title>`, html.EscapeString(n.ID))
+			fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="#f5f5f5" stroke="#333"/>`,
+				b.x, b.y, b.w, graphNodeHeight)
+			fmt.Fprintf(&buf,
+				`<text x="%d" y="%d" font-family="monospace" font-size="%d" text-anchor="middle" `+
+					`dominant-baseline="central">%s</text></g>`+"\n",
+				b.x+b.w/2, b.y+graphNodeHeight/2, graphFontSize, html.EscapeString(n.Label))
+		}
+	}
+
+	buf.WriteString("</svg>\n")
+
+	return buf.Bytes()
+}
+
+// layers assigns every node to the layer of its longest path to a node without
+// dependencies, so dependencies are always placed below their dependents.
+func (g *DependencyGraph) layers() [][]*GraphNode {
+	deps := make(map[string][]string)
+	for _, e := range g.Edges {
+		deps[e.From] = append(deps[e.From], e.To)
+	}
+
+	depth := make(map[string]int)
+	visiting := make(map[string]bool)
+
+	var visit func(id string) int
+
+	visit = func(id string) int {
+		if d, ok := depth[id]; ok {
+			return d
+		}
+
+		// Break cycles
+		if visiting[id] {
+			return 0
+		}
+
+		visiting[id] = true
+		d := 0
+
+		for _, dep := range deps[id] {
+			d = max(d, visit(dep)+1)
+		}
+
+		visiting[id] = false
+		depth[id] = d
+
+		return d
+	}
+
+	maxDepth := 0
+	for _, n := range g.Nodes {
+		maxDepth = max(maxDepth, visit(n.ID))
+	}
+
+	layers := make([][]*GraphNode, maxDepth+1)
+
+	for _, n := range g.Nodes {
+		// Nodes without dependencies are placed at the bottom
+		layer := maxDepth - depth[n.ID]
+		layers[layer] = append(layers[layer], n)
+	}
+
+	for _, layer := range layers {
+		sort.Slice(layer, func(i, j int) bool { return layer[i].Label < layer[j].Label })
+	}
+
+	g.reduceCrossings(layers, deps)
+
+	return layers
+}
+
+// reduceCrossings orders the nodes of each layer by the mean position of their
+// dependents in the layers above.
+func (g *DependencyGraph) reduceCrossings(layers [][]*GraphNode, deps map[string][]string) {
+	dependents := make(map[string][]string)
+
+	for from, tos := range deps {
+		for _, to := range tos {
+			dependents[to] = append(dependents[to], from)
+		}
+	}
+
+	for range graphMaxLayerScan {
+		position := make(map[string]int)
+
+		for _, layer := range layers {
+			weight := make(map[string]float64)
+
+			for j, n := range layer {
+				sum, count := 0, 0
+
+				for _, dependent := range dependents[n.ID] {
+					if pos, ok := position[dependent]; ok {
+						sum += pos
+						count++
+					}
+				}
+
+				weight[n.ID] = float64(j)
+				if count > 0 {
+					weight[n.ID] = float64(sum) / float64(count)
+				}
+			}
+
+			sort.SliceStable(layer, func(a, b int) bool { return weight[layer[a].ID] < weight[layer[b].ID] })
+
+			for j, n := range layer {
+				position[n.ID] = j
+			}
+		}
+	}
+}
+
+func nodeWidth(n *GraphNode) int {
+	return len([]rune(n.Label))*graphCharWidth + graphNodePadding
+}
+
+type dotToken struct {
+	value  string
+	quoted bool
+}
+
+type dotTokens []dotToken
+
+func (t dotToken) punct(p string) bool {
+	return !t.quoted && t.value == p
+}
+
+func (t dotToken) keyword(k string) bool {
+	return !t.quoted && strings.EqualFold(t.value, k)
+}
+
+func (t dotToken) id() bool {
+	if t.quoted {
+		return true
+	}
+
+	return t.value != "" && !strings.ContainsAny(t.value[:1], "{}[];=,-")
+}
+
+func (t dotTokens) at(i int) dotToken {
+	if i < 0 || i >= len(t) {
+		return dotToken{}
+	}
+
+	return t[i]
+}
+
+// attrs parses the attribute list starting at the opening bracket at position i
+// and returns the attributes and the position after the closing bracket.
+func (t dotTokens) attrs(i int) (map[string]string, int, error) {
+	attrs := make(map[string]string)
+
+	for i++; i < len(t); {
+		switch {
+		case t[i].punct("]"):
+			return attrs, i + 1, nil
+		case t[i].punct(","), t[i].punct(";"):
+			i++
+		case t[i].id() && t.at(i+1).punct("=") && t.at(i+2).id():
+			attrs[t[i].value] = t[i+2].value
+			i += 3
+		default:
+			return nil, i, fmt.Errorf("%w: invalid attribute %q", ErrGraphSyntax, t[i].value)
+		}
+	}
+
+	return nil, i, fmt.Errorf("%w: unterminated attribute list", ErrGraphSyntax)
+}
+
+func tokenizeDOT(dot string) (dotTokens, error) {
+	tokens := make(dotTokens, 0)
+
+	for i := 0; i < len(dot); {
+		c := dot[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '/' && strings.HasPrefix(dot[i:], "//"), c == '#':
+			end := strings.IndexByte(dot[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+
+			i += end
+		case c == '/' && strings.HasPrefix(dot[i:], "/*"):
+			end := strings.Index(dot[i:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated comment", ErrGraphSyntax)
+			}
+
+			i += end + 2
+		case c == '"':
+			end := i + 1
+			for end < len(dot) && dot[end] != '"' {
+				if dot[end] == '\\' {
+					end++
+				}
+
+				end++
+			}
+
+			if end >= len(dot) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrGraphSyntax)
+			}
+
+			value, err := strconv.Unquote(dot[i : end+1])
+			if err != nil {
+				// DOT only escapes quotes
+				value = strings.ReplaceAll(dot[i+1:end], `\"`, `"`)
+			}
+
+			tokens = append(tokens, dotToken{value: value, quoted: true})
+			i = end + 1
+		case strings.HasPrefix(dot[i:], "->"), strings.HasPrefix(dot[i:], "--"):
+			tokens = append(tokens, dotToken{value: dot[i : i+2]})
+			i += 2
+		case strings.IndexByte("{}[];=,", c) >= 0:
+			tokens = append(tokens, dotToken{value: string(c)})
+			i++
+		default:
+			end := i
+			for end < len(dot) && strings.IndexByte(" \t\r\n{}[];=,\"", dot[end]) < 0 &&
+				!strings.HasPrefix(dot[end:], "->") {
+				end++
+			}
+
+			tokens = append(tokens, dotToken{value: dot[i:end]})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,161 @@
+package tofu
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTofu_Graph(t *testing.T) {
+	tests := []struct {
+		name      string
+		tofu      *Tofu
+		graphType string
+		want      []string
+	}{
+		{
+			name:      "plan graph",
+			tofu:      &Tofu{},
+			graphType: GraphTypePlan,
+			want:      []string{TofuBin, "graph", "-type=plan"},
+		},
+		{
+			name:      "apply graph from plan file",
+			tofu:      &Tofu{OutFile: "plan.tfout"},
+			graphType: GraphTypeApply,
+			want:      []string{TofuBin, "graph", "-plan=plan.tfout"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.Graph(tt.graphType)
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestParseGraph(t *testing.T) {
+	tests := []struct {
+		name      string
+		dot       string
+		wantNodes []GraphNode
+		wantEdges []GraphEdge
+		wantErr   error
+	}{
+		{
+			name: "legacy graph output",
+			dot: `digraph {
+	compound = "true"
+	newrank = "true"
+	subgraph "root" {
+		"[root] aws_instance.web (expand)" [label = "aws_instance.web", shape = "box"]
+		"[root] provider[\"example.com/acme/aws\"]" [label = "provider[\"example.com/acme/aws\"]", shape = "diamond"]
+		"[root] aws_instance.web (expand)" -> "[root] provider[\"example.com/acme/aws\"]"
+	}
+}`,
+			wantNodes: []GraphNode{
+				{ID: "[root] aws_instance.web (expand)", Label: "aws_instance.web"},
+				{
+					ID:    `[root] provider["example.com/acme/aws"]`,
+					Label: `provider["example.com/acme/aws"]`,
+				},
+			},
+			wantEdges: []GraphEdge{
+				{From: "[root] aws_instance.web (expand)", To: `[root] provider["example.com/acme/aws"]`},
+			},
+		},
+		{
+			name: "simplified graph output",
+			dot: `digraph G {
+  rankdir = "RL";
+  node [shape = rect, fontname = "sans-serif"];
+  "aws_instance.web" [label="aws_instance.web"];
+  subgraph "cluster_module.db" {
+    label = "module.db"
+    fontname = "sans-serif"
+    "module.db.aws_db_instance.main" [label="aws_db_instance.main"];
+  }
+  "aws_instance.web" -> "module.db.aws_db_instance.main";
+}`,
+			wantNodes: []GraphNode{
+				{ID: "aws_instance.web", Label: "aws_instance.web"},
+				{ID: "module.db.aws_db_instance.main", Label: "aws_db_instance.main"},
+			},
+			wantEdges: []GraphEdge{
+				{From: "aws_instance.web", To: "module.db.aws_db_instance.main"},
+			},
+		},
+		{
+			name:      "edge chain",
+			dot:       `digraph { a -> b -> c }`,
+			wantNodes: []GraphNode{{ID: "a", Label: "a"}, {ID: "b", Label: "b"}, {ID: "c", Label: "c"}},
+			wantEdges: []GraphEdge{{From: "a", To: "b"}, {From: "b", To: "c"}},
+		},
+		{
+			name:    "unterminated string",
+			dot:     `digraph { "a -> b }`,
+			wantErr: ErrGraphSyntax,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGraph([]byte(tt.dot))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			nodes := make([]GraphNode, 0, len(got.Nodes))
+			for _, n := range got.Nodes {
+				nodes = append(nodes, *n)
+			}
+
+			edges := make([]GraphEdge, 0, len(got.Edges))
+			for _, e := range got.Edges {
+				edges = append(edges, *e)
+			}
+
+			assert.Equal(t, tt.wantNodes, nodes)
+			assert.Equal(t, tt.wantEdges, edges)
+		})
+	}
+}
+
+func TestDependencyGraph_SVG(t *testing.T) {
+	graph, err := ParseGraph([]byte(`digraph {
+		"app" -> "db"
+		"app" -> "network"
+		"db" -> "network"
+		"label" [label = "<escaped> & \"quoted\""]
+	}`))
+	require.NoError(t, err)
+
+	layers := graph.layers()
+	require.Len(t, layers, 3)
+	assert.Equal(t, "app", layers[0][0].ID)
+	assert.Equal(t, "db", layers[1][0].ID)
+	assert.ElementsMatch(t, []string{"network", "label"}, []string{layers[2][0].ID, layers[2][1].ID})
+
+	svg := graph.SVG()
+	assert.Equal(t, 3, strings.Count(string(svg), "<line "))
+	assert.Equal(t, 4, strings.Count(string(svg), "<rect "))
+	assert.Contains(t, string(svg), "&lt;escaped&gt; &amp; &#34;quoted&#34;")
+
+	decoder := xml.NewDecoder(strings.NewReader(string(svg)))
+	for {
+		_, err := decoder.Token()
+		if err != nil {
+			assert.Equal(t, "EOF", err.Error())
+
+			break
+		}
+	}
+}
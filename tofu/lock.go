@@ -0,0 +1,104 @@
+package tofu
+
+import (
+	"strings"
+	"time"
+
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+// lockCreatedLayout is the format of the creation time in the lock info block.
+const lockCreatedLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// LockInfo holds the state lock information printed by OpenTofu if the state
+// lock cannot be acquired.
+type LockInfo struct {
+	ID        string
+	Path      string
+	Operation string
+	Who       string
+	Version   string
+	Created   time.Time
+}
+
+// ParseLockInfo extracts the lock info block from the output of a command that
+// failed to acquire the state lock.
+func ParseLockInfo(output string) (*LockInfo, bool) {
+	_, block, ok := strings.Cut(output, "Lock Info:")
+	if !ok {
+		return nil, false
+	}
+
+	info := &LockInfo{}
+
+	for _, line := range strings.Split(block, "\n") {
+		// Diagnostics are framed by box drawing characters
+		line = strings.TrimSpace(strings.TrimLeft(line, "│║| \t"))
+		if line == "" {
+			if info.ID != "" {
+				break
+			}
+
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			break
+		}
+
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "ID":
+			info.ID = value
+		case "Path":
+			info.Path = value
+		case "Operation":
+			info.Operation = value
+		case "Who":
+			info.Who = value
+		case "Version":
+			info.Version = value
+		case "Created":
+			if created, err := time.Parse(lockCreatedLayout, value); err == nil {
+				info.Created = created
+			}
+		}
+	}
+
+	if info.ID == "" {
+		return nil, false
+	}
+
+	return info, true
+}
+
+// lockProbeAddress is the resource address removed by the lock probe in dry run mode.
+// It does not need to exist, the probe only succeeds in acquiring the state lock.
+const lockProbeAddress = "terraform_data.wp_opentofu_lock_probe"
+
+// LockProbe returns a command that tries to acquire the state lock without waiting.
+// The state is only read, no configuration is evaluated and nothing is written.
+// If the state is locked, the command fails and prints the lock info block.
+func (t *Tofu) LockProbe() *plugin_exec.Cmd {
+	cmd := t.command("state", "rm", "-dry-run", "-lock=true", "-lock-timeout=0s", lockProbeAddress)
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+// LockAcquired reports whether the stderr of a failed lock probe shows that the state
+// lock was acquired before the command failed, because no state or no resource
+// matching the probe address exists.
+func LockAcquired(stderr string) bool {
+	return NoStateFound(stderr) || strings.Contains(stderr, "No matching objects found")
+}
+
+func (t *Tofu) ForceUnlock(id string) *plugin_exec.Cmd {
+	cmd := t.command("force-unlock", "-force", id)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
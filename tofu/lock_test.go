@@ -0,0 +1,113 @@
+package tofu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLockInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *LockInfo
+		wantOk bool
+	}{
+		{
+			name: "lock error diagnostic",
+			output: `╷
+│ Error: Error acquiring the state lock
+│ 
+│ Error message: ConditionalCheckFailedException: The conditional request failed
+│ Lock Info:
+│   ID:        0c5f3ed8-8ab8-1e8e-4d8c-5a9f1d0e7a1c
+│   Path:      bucket/prod/terraform.tfstate
+│   Operation: OperationTypeApply
+│   Who:       runner@ci-agent-1
+│   Version:   1.8.0
+│   Created:   2026-10-19 08:15:30.123456789 +0000 UTC
+│   Info:      
+│ 
+│ 
+│ OpenTofu acquires a state lock to protect the state from being written
+│ by multiple users at the same time.
+╵
+`,
+			want: &LockInfo{
+				ID:        "0c5f3ed8-8ab8-1e8e-4d8c-5a9f1d0e7a1c",
+				Path:      "bucket/prod/terraform.tfstate",
+				Operation: "OperationTypeApply",
+				Who:       "runner@ci-agent-1",
+				Version:   "1.8.0",
+				Created:   time.Date(2026, 10, 19, 8, 15, 30, 123456789, time.UTC),
+			},
+			wantOk: true,
+		},
+		{
+			name:   "unrelated error",
+			output: "Error: Invalid resource type\n",
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseLockInfo(tt.output)
+			assert.Equal(t, tt.wantOk, ok)
+
+			if tt.want == nil {
+				assert.Nil(t, got)
+
+				return
+			}
+
+			assert.True(t, tt.want.Created.Equal(got.Created))
+
+			got.Created = tt.want.Created
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTofu_LockProbe(t *testing.T) {
+	cmd := (&Tofu{}).LockProbe()
+	assert.Equal(t, []string{
+		TofuBin, "state", "rm", "-dry-run", "-lock=true", "-lock-timeout=0s", "terraform_data.wp_opentofu_lock_probe",
+	}, cmd.Args)
+	assert.Nil(t, cmd.Stdout)
+}
+
+func TestLockAcquired(t *testing.T) {
+	assert.True(t, LockAcquired("Error: Invalid target address\n\nNo matching objects found."))
+	assert.True(t, LockAcquired("No state file was found!"))
+	assert.False(t, LockAcquired("Error: Error acquiring the state lock"))
+	assert.False(t, LockAcquired("Error: Failed to get existing workspaces: AccessDenied"))
+}
+
+func TestTofu_ForceUnlock(t *testing.T) {
+	cmd := (&Tofu{}).ForceUnlock("0c5f3ed8")
+	assert.Equal(t, []string{TofuBin, "force-unlock", "-force", "0c5f3ed8"}, cmd.Args)
+}
+
+func TestParseLockInfo_DiagnosticWriter(t *testing.T) {
+	var out bytes.Buffer
+
+	w := NewDiagnosticWriter(&out)
+
+	//nolint:lll
+	_, _ = w.Write([]byte(`{"@level":"info","@message":"OpenTofu 1.8.0","type":"version"}
+{"@level":"error","@message":"Error: Error acquiring the state lock","diagnostic":{"severity":"error","summary":"Error acquiring the state lock","detail":"Error message: ConditionalCheckFailedException: The conditional request failed\nLock Info:\n  ID:        0c5f3ed8-8ab8-1e8e-4d8c-5a9f1d0e7a1c\n  Path:      bucket/prod/terraform.tfstate\n  Operation: OperationTypeApply\n  Who:       runner@ci-agent-1\n  Version:   1.8.0\n  Created:   2026-10-19 08:15:30.123456789 +0000 UTC\n  Info:      \n\n\nOpenTofu acquires a state lock to protect the state from being written\nby multiple users at the same time."},"type":"diagnostic"}`))
+	w.Flush()
+
+	assert.True(t, strings.HasPrefix(out.String(), "Error: Error acquiring the state lock\n"))
+
+	info, ok := ParseLockInfo(out.String())
+	require.True(t, ok)
+	assert.Equal(t, "0c5f3ed8-8ab8-1e8e-4d8c-5a9f1d0e7a1c", info.ID)
+	assert.Equal(t, "runner@ci-agent-1", info.Who)
+	assert.Equal(t, time.Date(2026, 10, 19, 8, 15, 30, 123456789, time.UTC), info.Created)
+}
@@ -0,0 +1,39 @@
+package tofu
+
+import (
+	"bytes"
+)
+
+// lineWriter is an io.Writer that calls handle for every complete line written to it.
+type lineWriter struct {
+	buf    []byte
+	handle func(line []byte)
+}
+
+func newLineWriter(handle func(line []byte)) *lineWriter {
+	return &lineWriter{handle: handle}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.handle(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush handles remaining data without a trailing newline.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.handle(w.buf)
+		w.buf = nil
+	}
+}
@@ -0,0 +1,55 @@
+package tofu
+
+import (
+	"path/filepath"
+)
+
+const (
+	// PluginCacheDirEnv is the environment variable to set the provider plugin cache directory.
+	PluginCacheDirEnv = "TF_PLUGIN_CACHE_DIR"
+	// PluginCacheMayBreakEnv is the environment variable to allow the plugin cache to be used
+	// even if the dependency lock file does not contain a matching checksum.
+	PluginCacheMayBreakEnv = "TF_PLUGIN_CACHE_MAY_BREAK_DEPENDENCY_LOCK_FILE"
+)
+
+// ProviderPackages returns the provider packages found in a plugin cache or
+// provider install directory. Packages are returned relative to dir in the
+// format `<hostname>/<namespace>/<type>/<version>/<os_arch>`.
+func ProviderPackages(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*", "*", "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		rel, err := filepath.Rel(dir, match)
+		if err != nil {
+			return nil, err
+		}
+
+		packages = append(packages, filepath.ToSlash(rel))
+	}
+
+	return packages, nil
+}
+
+// PluginCacheStats compares the installed provider packages with the packages
+// available in the plugin cache before installation.
+func PluginCacheStats(cached, installed []string) (int, int) {
+	cache := make(map[string]struct{}, len(cached))
+	for _, pkg := range cached {
+		cache[pkg] = struct{}{}
+	}
+
+	hits := 0
+
+	for _, pkg := range installed {
+		if _, ok := cache[pkg]; ok {
+			hits++
+		}
+	}
+
+	return hits, len(installed) - hits
+}
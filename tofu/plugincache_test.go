@@ -0,0 +1,65 @@
+package tofu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderPackages(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, pkg := range []string{
+		"registry.opentofu.org/hashicorp/aws/5.0.0/linux_amd64",
+		"registry.opentofu.org/hashicorp/null/3.2.1/linux_amd64",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, pkg), 0o755))
+	}
+
+	got, err := ProviderPackages(dir)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"registry.opentofu.org/hashicorp/aws/5.0.0/linux_amd64",
+		"registry.opentofu.org/hashicorp/null/3.2.1/linux_amd64",
+	}, got)
+
+	got, err = ProviderPackages(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestPluginCacheStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		cached     []string
+		installed  []string
+		wantHits   int
+		wantMisses int
+	}{
+		{
+			name:       "empty cache",
+			cached:     []string{},
+			installed:  []string{"a/b/c/1.0.0/linux_amd64"},
+			wantHits:   0,
+			wantMisses: 1,
+		},
+		{
+			name:       "partial cache",
+			cached:     []string{"a/b/c/1.0.0/linux_amd64", "a/b/d/1.0.0/linux_amd64"},
+			installed:  []string{"a/b/c/1.0.0/linux_amd64", "a/b/e/1.0.0/linux_amd64"},
+			wantHits:   1,
+			wantMisses: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, misses := PluginCacheStats(tt.cached, tt.installed)
+			assert.Equal(t, tt.wantHits, hits)
+			assert.Equal(t, tt.wantMisses, misses)
+		})
+	}
+}
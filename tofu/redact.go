@@ -0,0 +1,190 @@
+package tofu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	redactMask = "***"
+
+	// Shorter values are too likely to occur in regular output.
+	redactMinLength = 4
+)
+
+// Redactor masks secret values and common credential patterns in command output.
+type Redactor struct {
+	replacer *strings.Replacer
+	patterns []redactPattern
+}
+
+type redactPattern struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// RedactWriter is an io.Writer that redacts the output line by line before it is
+// written to the underlying writer.
+type RedactWriter struct {
+	*lineWriter
+	out        io.Writer
+	redactor   *Redactor
+	privateKey bool
+}
+
+// NewRedactor creates a Redactor masking the given secrets. Values that are too short
+// or booleans and numbers are ignored. Common credential patterns are only masked if
+// patterns is set, as they may also match harmless output.
+func NewRedactor(secrets []string, patterns bool) *Redactor {
+	values := make([]string, 0, len(secrets))
+	seen := make(map[string]bool)
+
+	for _, secret := range secrets {
+		if !isRedactable(secret) {
+			continue
+		}
+
+		variants := []string{secret}
+
+		// Secrets may also be printed as part of JSON output
+		if encoded, err := json.Marshal(secret); err == nil {
+			variants = append(variants, strings.Trim(string(encoded), `"`))
+		}
+
+		for _, value := range variants {
+			if !seen[value] {
+				seen[value] = true
+
+				values = append(values, value)
+			}
+		}
+	}
+
+	// Longer secrets take precedence over secrets they contain
+	sort.SliceStable(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		pairs = append(pairs, value, redactMask)
+	}
+
+	r := &Redactor{
+		replacer: strings.NewReplacer(pairs...),
+	}
+
+	if patterns {
+		r.patterns = redactPatterns()
+	}
+
+	return r
+}
+
+// redactPatterns returns the patterns of common credentials.
+func redactPatterns() []redactPattern {
+	return []redactPattern{
+		// Cloud provider and SaaS access tokens
+		{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), redactMask},
+		{regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`), redactMask},
+		{regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`), redactMask},
+		{regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`), redactMask},
+		{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`), redactMask},
+		// Authorization headers
+		{regexp.MustCompile(`(?i)\b(bearer|basic)(\s+)[A-Za-z0-9._~+/=-]{8,}`), "${1}${2}" + redactMask},
+		// Credentials in URLs
+		{regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "${1}" + redactMask + "@"},
+		// Assignments of quoted literals to secret attributes, numbers like `token_ttl = "3600"` are kept
+		{
+			regexp.MustCompile(
+				`(?i)([A-Za-z0-9_-]*(?:password|passwd|secret|token|api_?key|access_?key|secret_?key|client_?secret|private_?key)` +
+					`[A-Za-z0-9_-]*"?\s*[:=]\s*")([^"\s]*[^"\s0-9.+-][^"\s]*)"`,
+			),
+			"${1}" + redactMask + `"`,
+		},
+	}
+}
+
+// Redact masks all secrets and credential patterns in s. A nil Redactor returns s unchanged.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+
+	s = r.replacer.Replace(s)
+
+	for _, pattern := range r.patterns {
+		s = pattern.re.ReplaceAllString(s, pattern.repl)
+	}
+
+	return s
+}
+
+// RedactDiagnostics masks the summary and detail of all diagnostics in place.
+func (r *Redactor) RedactDiagnostics(diags []*Diagnostic) {
+	for _, diag := range diags {
+		diag.Summary = r.Redact(diag.Summary)
+		diag.Detail = r.Redact(diag.Detail)
+	}
+}
+
+// RedactTestReport masks the diagnostics of all test files and runs in place.
+func (r *Redactor) RedactTestReport(report *TestReport) {
+	for _, file := range report.Files {
+		for i, diag := range file.Diagnostics {
+			file.Diagnostics[i] = r.Redact(diag)
+		}
+
+		for _, run := range file.Runs {
+			for i, diag := range run.Diagnostics {
+				run.Diagnostics[i] = r.Redact(diag)
+			}
+		}
+	}
+}
+
+// Writer returns a RedactWriter writing redacted output to out.
+func (r *Redactor) Writer(out io.Writer) *RedactWriter {
+	w := &RedactWriter{
+		out:      out,
+		redactor: r,
+	}
+	w.lineWriter = newLineWriter(w.handle)
+
+	return w
+}
+
+func (w *RedactWriter) handle(line []byte) {
+	text := string(line)
+
+	// Private keys span multiple lines, mask everything between the markers
+	switch {
+	case strings.Contains(text, "-----BEGIN") && strings.Contains(text, "PRIVATE KEY-----"):
+		w.privateKey = !strings.Contains(text, "-----END")
+	case w.privateKey && strings.Contains(text, "-----END"):
+		w.privateKey = false
+	case w.privateKey:
+		text = redactMask
+	}
+
+	fmt.Fprintln(w.out, w.redactor.Redact(text))
+}
+
+func isRedactable(value string) bool {
+	if len(value) < redactMinLength {
+		return false
+	}
+
+	if _, err := strconv.ParseBool(value); err == nil {
+		return false
+	}
+
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return false
+	}
+
+	return true
+}
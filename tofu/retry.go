@@ -0,0 +1,164 @@
+package tofu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+// RetryPolicy defines how commands failing with a transient error are retried.
+type RetryPolicy struct {
+	MaxRetries int64
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// sleep is replaceable for testing.
+	sleep func(time.Duration)
+}
+
+// retryableErrors are stderr patterns of state lock contention and transient
+// provider or network errors.
+func retryableErrors() []string {
+	return []string{
+		"Error acquiring the state lock",
+		"connection reset by peer",
+		"connection refused",
+		"i/o timeout",
+		"TLS handshake timeout",
+		"timeout awaiting response headers",
+		"unexpected EOF",
+		"429 Too Many Requests",
+		"502 Bad Gateway",
+		"503 Service Unavailable",
+		"504 Gateway Timeout",
+		"Failed to query available provider packages",
+		"Failed to install provider",
+	}
+}
+
+// mutationMarkers are stdout patterns indicating that resources are being changed.
+func mutationMarkers() []string {
+	return []string{
+		": Creating...",
+		": Modifying...",
+		": Destroying...",
+		": Importing from ID",
+	}
+}
+
+// Run runs the command bound to ctx and retries it with exponential backoff if it fails
+// with a retryable error. Errors are detected on stderr and in the diagnostics of JSON
+// output. A command that already started to change resources is never retried. See
+// BindContext for the handling of the grace period.
+func (r *RetryPolicy) Run(ctx context.Context, cmd *plugin_exec.Cmd, grace time.Duration) error {
+	stdout, stderr := cmd.Stdout, cmd.Stderr
+
+	for attempt := int64(1); ; attempt++ {
+		// A command cannot be run more than once, every attempt runs a copy
+		run := BindContext(ctx, cmd, grace)
+
+		// Stdout and stderr are written concurrently and need separate buffers
+		var errOutput, diagOutput bytes.Buffer
+
+		diagWriter := NewDiagnosticWriter(&diagOutput)
+
+		mutated := false
+		mutationWriter := newLineWriter(func(line []byte) {
+			for _, marker := range mutationMarkers() {
+				if bytes.Contains(line, []byte(marker)) {
+					mutated = true
+				}
+			}
+		})
+
+		run.Stdout = teeWriter(stdout, io.MultiWriter(mutationWriter, diagWriter))
+		run.Stderr = teeWriter(stderr, &errOutput)
+
+		err := run.Run()
+
+		// A last line without trailing newline may still report a change
+		mutationWriter.Flush()
+		diagWriter.Flush()
+
+		if err != nil && ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+
+		if err == nil || attempt > r.MaxRetries {
+			return err
+		}
+
+		if mutated {
+			log.Warn().Msg("command failed after resources were changed, not retrying")
+
+			return err
+		}
+
+		if !isRetryable(errOutput.String()) && !isRetryable(diagOutput.String()) {
+			return err
+		}
+
+		delay := r.delay(attempt)
+
+		log.Warn().
+			Int64("attempt", attempt).
+			Int64("max_retries", r.MaxRetries).
+			Dur("delay", delay).
+			Msgf("command failed with a retryable error: %v", err)
+
+		if r.sleep != nil {
+			r.sleep(delay)
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// delay returns the exponential backoff delay before the given retry attempt.
+func (r *RetryPolicy) delay(attempt int64) time.Duration {
+	delay := r.Backoff
+
+	for i := int64(1); i < attempt; i++ {
+		delay *= 2
+
+		if r.MaxBackoff > 0 && delay >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+
+	return delay
+}
+
+func isRetryable(output string) bool {
+	for _, pattern := range retryableErrors() {
+		if strings.Contains(output, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func teeWriter(w, tee io.Writer) io.Writer {
+	if w == nil {
+		return tee
+	}
+
+	return io.MultiWriter(w, tee)
+}
@@ -0,0 +1,143 @@
+package tofu
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+// attemptScript returns a shell script failing with stderr until the given attempt.
+// The stdout is printed as printf format.
+func attemptScript(counter, stdout, stderr string, succeedAt int) string {
+	return `n=$(($(cat ` + counter + ` 2>/dev/null || echo 0) + 1)); echo $n > ` + counter + `; ` +
+		`printf "` + stdout + `"; if [ $n -lt ` + strconv.Itoa(succeedAt) + ` ]; then echo "` + stderr + `" >&2; exit 1; fi`
+}
+
+func TestRetryPolicy_Run(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		stdout       string
+		stderr       string
+		succeedAt    int
+		wantErr      bool
+		wantAttempts string
+		wantDelays   []time.Duration
+	}{
+		{
+			name:         "retry on lock error",
+			policy:       RetryPolicy{MaxRetries: 3, Backoff: time.Second, MaxBackoff: time.Minute},
+			stderr:       "Error: Error acquiring the state lock",
+			succeedAt:    3,
+			wantAttempts: "3",
+			wantDelays:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:   "retry on lock error in json output",
+			policy: RetryPolicy{MaxRetries: 3, Backoff: time.Second},
+			stdout: `{\"type\":\"diagnostic\",\"diagnostic\":` +
+				`{\"severity\":\"error\",\"summary\":\"Error acquiring the state lock\"}}\n`,
+			succeedAt:    2,
+			wantAttempts: "2",
+			wantDelays:   []time.Duration{time.Second},
+		},
+		{
+			name:         "retries exhausted",
+			policy:       RetryPolicy{MaxRetries: 1, Backoff: time.Second},
+			stderr:       "dial tcp: i/o timeout",
+			succeedAt:    5,
+			wantErr:      true,
+			wantAttempts: "2",
+			wantDelays:   []time.Duration{time.Second},
+		},
+		{
+			name:         "no retry on permanent error",
+			policy:       RetryPolicy{MaxRetries: 3, Backoff: time.Second},
+			stderr:       "Error: Invalid resource type",
+			succeedAt:    5,
+			wantErr:      true,
+			wantAttempts: "1",
+		},
+		{
+			name:         "no retry after resources changed",
+			policy:       RetryPolicy{MaxRetries: 3, Backoff: time.Second},
+			stdout:       "aws_instance.web: Creating...\\n",
+			stderr:       "connection reset by peer",
+			succeedAt:    5,
+			wantErr:      true,
+			wantAttempts: "1",
+		},
+		{
+			name:         "no retry after resources changed without trailing newline",
+			policy:       RetryPolicy{MaxRetries: 3, Backoff: time.Second},
+			stdout:       "aws_instance.web: Destroying...",
+			stderr:       "connection reset by peer",
+			succeedAt:    5,
+			wantErr:      true,
+			wantAttempts: "1",
+		},
+		{
+			name:         "no retry on deadline exceeded",
+			policy:       RetryPolicy{MaxRetries: 3, Backoff: time.Second},
+			stderr:       "Error: context deadline exceeded",
+			succeedAt:    5,
+			wantErr:      true,
+			wantAttempts: "1",
+		},
+		{
+			name:         "retry disabled",
+			policy:       RetryPolicy{},
+			stderr:       "Error: Error acquiring the state lock",
+			succeedAt:    5,
+			wantErr:      true,
+			wantAttempts: "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := filepath.Join(t.TempDir(), "counter")
+			delays := make([]time.Duration, 0)
+
+			policy := tt.policy
+			policy.sleep = func(d time.Duration) {
+				delays = append(delays, d)
+			}
+
+			cmd := plugin_exec.Command("sh", "-c", attemptScript(counter, tt.stdout, tt.stderr, tt.succeedAt))
+			cmd.Trace = false
+
+			err := policy.Run(context.Background(), cmd, 0)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			attempts, _ := os.ReadFile(counter)
+			assert.Equal(t, tt.wantAttempts+"\n", string(attempts))
+
+			if tt.wantDelays == nil {
+				tt.wantDelays = []time.Duration{}
+			}
+
+			assert.Equal(t, tt.wantDelays, delays)
+		})
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 10 * time.Second, MaxBackoff: time.Minute}
+
+	assert.Equal(t, 10*time.Second, policy.delay(1))
+	assert.Equal(t, 20*time.Second, policy.delay(2))
+	assert.Equal(t, 40*time.Second, policy.delay(3))
+	assert.Equal(t, time.Minute, policy.delay(4))
+	assert.Equal(t, time.Minute, policy.delay(10))
+}
@@ -0,0 +1,159 @@
+package tofu
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+//nolint:tagliatelle
+type sarifLog struct {
+	Version string      `json:"version"`
+	Schema  string      `json:"$schema"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool      `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+//nolint:tagliatelle
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+//nolint:tagliatelle
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+//nolint:tagliatelle
+type sarifResult struct {
+	RuleID    string           `json:"ruleId"`
+	Level     string           `json:"level"`
+	Message   sarifMessage     `json:"message"`
+	Locations []*sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+//nolint:tagliatelle
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+//nolint:tagliatelle
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+//nolint:tagliatelle
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// Annotation returns the diagnostic as `file:line:column: severity: summary` with the
+// file relative to the root dir, as understood by most annotation tools.
+func (d *Diagnostic) Annotation(root string) string {
+	if d.Range == nil {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Summary)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s: %s",
+		filepath.Join(root, d.Range.Filename), d.Range.Start.Line, d.Range.Start.Column, d.Severity, d.Summary)
+}
+
+// SARIF returns the diagnostics in SARIF format. The file paths of all locations are
+// prefixed with the root dir.
+func (r *ValidateResult) SARIF(root string) ([]byte, error) {
+	run := &sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "OpenTofu",
+				InformationURI: "https://opentofu.org",
+				Rules:          make([]*sarifRule, 0),
+			},
+		},
+		Results: make([]*sarifResult, 0, len(r.Diagnostics)),
+	}
+
+	rules := make(map[string]bool)
+
+	for _, diag := range r.Diagnostics {
+		// Diagnostics have no code, the summary identifies the kind of problem
+		if !rules[diag.Summary] {
+			rules[diag.Summary] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, &sarifRule{
+				ID:               diag.Summary,
+				ShortDescription: sarifMessage{Text: diag.Summary},
+			})
+		}
+
+		text := diag.Summary
+		if diag.Detail != "" {
+			text = fmt.Sprintf("%s\n\n%s", text, diag.Detail)
+		}
+
+		level := "error"
+		if diag.Severity == DiagnosticSeverityWarning {
+			level = "warning"
+		}
+
+		result := &sarifResult{
+			RuleID:  diag.Summary,
+			Level:   level,
+			Message: sarifMessage{Text: text},
+		}
+
+		if diag.Range != nil {
+			result.Locations = []*sarifLocation{
+				{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{
+							URI: path.Join(filepath.ToSlash(root), filepath.ToSlash(diag.Range.Filename)),
+						},
+						Region: sarifRegion{
+							StartLine:   diag.Range.Start.Line,
+							StartColumn: diag.Range.Start.Column,
+							EndLine:     diag.Range.End.Line,
+							EndColumn:   diag.Range.End.Column,
+						},
+					},
+				},
+			}
+		}
+
+		run.Results = append(run.Results, result)
+	}
+
+	out, err := json.MarshalIndent(&sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []*sarifRun{run}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, '\n'), nil
+}
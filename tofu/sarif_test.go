@@ -0,0 +1,93 @@
+package tofu
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostic_Annotation(t *testing.T) {
+	tests := []struct {
+		name string
+		diag *Diagnostic
+		root string
+		want string
+	}{
+		{
+			name: "diagnostic with range",
+			diag: &Diagnostic{
+				Severity: DiagnosticSeverityError,
+				Summary:  "Unsupported argument",
+				Range:    &DiagnosticRange{Filename: "main.tf", Start: DiagnosticPos{Line: 2, Column: 3}},
+			},
+			root: "infra",
+			want: "infra/main.tf:2:3: error: Unsupported argument",
+		},
+		{
+			name: "diagnostic without range",
+			diag: &Diagnostic{Severity: DiagnosticSeverityWarning, Summary: "Deprecated provider"},
+			want: "warning: Deprecated provider",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.diag.Annotation(tt.root))
+		})
+	}
+}
+
+func TestValidateResult_SARIF(t *testing.T) {
+	result := &ValidateResult{
+		ErrorCount:   2,
+		WarningCount: 1,
+		Diagnostics: []*Diagnostic{
+			{
+				Severity: DiagnosticSeverityError,
+				Summary:  "Unsupported argument",
+				Detail:   `An argument named "foo" is not expected here.`,
+				Range: &DiagnosticRange{
+					Filename: "main.tf",
+					Start:    DiagnosticPos{Line: 2, Column: 3},
+					End:      DiagnosticPos{Line: 2, Column: 6},
+				},
+			},
+			{
+				Severity: DiagnosticSeverityError,
+				Summary:  "Unsupported argument",
+				Range:    &DiagnosticRange{Filename: "modules/db/main.tf", Start: DiagnosticPos{Line: 7, Column: 1}},
+			},
+			{
+				Severity: DiagnosticSeverityWarning,
+				Summary:  "Deprecated attribute",
+			},
+		},
+	}
+
+	out, err := result.SARIF("infra")
+	require.NoError(t, err)
+
+	report := sarifLog{}
+	require.NoError(t, json.Unmarshal(out, &report))
+
+	assert.Equal(t, sarifVersion, report.Version)
+	require.Len(t, report.Runs, 1)
+
+	run := report.Runs[0]
+	assert.Equal(t, []*sarifRule{
+		{ID: "Unsupported argument", ShortDescription: sarifMessage{Text: "Unsupported argument"}},
+		{ID: "Deprecated attribute", ShortDescription: sarifMessage{Text: "Deprecated attribute"}},
+	}, run.Tool.Driver.Rules)
+
+	require.Len(t, run.Results, 3)
+	assert.Equal(t, "error", run.Results[0].Level)
+	assert.Equal(t, "Unsupported argument\n\nAn argument named \"foo\" is not expected here.", run.Results[0].Message.Text)
+	assert.Equal(t, "infra/main.tf", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, sarifRegion{StartLine: 2, StartColumn: 3, EndLine: 2, EndColumn: 6},
+		run.Results[0].Locations[0].PhysicalLocation.Region)
+	assert.Equal(t, "infra/modules/db/main.tf", run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "warning", run.Results[2].Level)
+	assert.Empty(t, run.Results[2].Locations)
+}
@@ -0,0 +1,283 @@
+package tofu
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
+)
+
+const (
+	TestStatusPass  = "pass"
+	TestStatusFail  = "fail"
+	TestStatusError = "error"
+	TestStatusSkip  = "skip"
+)
+
+// TestOptions include options for the OpenTofu test command.
+type TestOptions struct {
+	Filter        []string          `json:"filter"`
+	TestDirectory string            `json:"test-directory"`
+	Var           map[string]string `json:"var"`
+	VarFile       []string          `json:"var-file"`
+	Verbose       *bool             `json:"verbose"`
+}
+
+// TestReport holds the results of a test run.
+type TestReport struct {
+	Files []*TestFile
+}
+
+// TestFile holds the results of a single test file.
+type TestFile struct {
+	Path        string
+	Status      string
+	Runs        []*TestRun
+	Diagnostics []string
+}
+
+// TestRun holds the result of a single run block.
+type TestRun struct {
+	Name        string
+	Status      string
+	Diagnostics []string
+}
+
+// TestOutput parses the machine readable output of `tofu test -json` while it is
+// streamed and writes the human readable messages to the underlying writer.
+type TestOutput struct {
+	*lineWriter
+	out    io.Writer
+	report *TestReport
+}
+
+//nolint:tagliatelle
+type testMessage struct {
+	Message  string `json:"@message"`
+	TestFile string `json:"@testfile"`
+	TestRun  string `json:"@testrun"`
+	File     *struct {
+		Path   string `json:"path"`
+		Status string `json:"status"`
+	} `json:"test_file"`
+	Run *struct {
+		Path   string `json:"path"`
+		Run    string `json:"run"`
+		Status string `json:"status"`
+	} `json:"test_run"`
+	Diagnostic *struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+		Detail   string `json:"detail"`
+	} `json:"diagnostic"`
+}
+
+func (t *Tofu) Test(jsonOutput bool) *plugin_exec.Cmd {
+	args := []string{
+		"test",
+	}
+
+	for _, v := range t.TestOptions.Filter {
+		args = append(args, fmt.Sprintf("-filter=%s", v))
+	}
+
+	if t.TestOptions.TestDirectory != "" {
+		args = append(args, fmt.Sprintf("-test-directory=%s", t.TestOptions.TestDirectory))
+	}
+
+	keys := make([]string, 0, len(t.TestOptions.Var))
+	for key := range t.TestOptions.Var {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		args = append(args, "-var", fmt.Sprintf("%s=%s", key, t.TestOptions.Var[key]))
+	}
+
+	for _, v := range t.TestOptions.VarFile {
+		args = append(args, fmt.Sprintf("-var-file=%s", v))
+	}
+
+	if t.TestOptions.Verbose != nil && *t.TestOptions.Verbose {
+		args = append(args, "-verbose")
+	}
+
+	if jsonOutput {
+		args = append(args, "-json")
+	}
+
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+// NewTestOutput creates a TestOutput writing human readable messages to out.
+func NewTestOutput(out io.Writer) *TestOutput {
+	o := &TestOutput{
+		out:    out,
+		report: &TestReport{},
+	}
+	o.lineWriter = newLineWriter(o.handle)
+
+	return o
+}
+
+// Report returns the collected test results.
+func (o *TestOutput) Report() *TestReport {
+	o.Flush()
+
+	return o.report
+}
+
+func (o *TestOutput) handle(line []byte) {
+	msg := testMessage{}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		fmt.Fprintln(o.out, string(line))
+
+		return
+	}
+
+	fmt.Fprintln(o.out, msg.Message)
+
+	switch {
+	case msg.Run != nil:
+		run := o.run(msg.Run.Path, msg.Run.Run)
+		run.Status = msg.Run.Status
+	case msg.File != nil:
+		file := o.file(msg.File.Path)
+		file.Status = msg.File.Status
+	case msg.Diagnostic != nil:
+		diag := msg.Diagnostic.Summary
+		if msg.Diagnostic.Detail != "" {
+			fmt.Fprintln(o.out, msg.Diagnostic.Detail)
+
+			diag = fmt.Sprintf("%s\n\n%s", diag, msg.Diagnostic.Detail)
+		}
+
+		switch {
+		case msg.TestRun != "":
+			run := o.run(msg.TestFile, msg.TestRun)
+			run.Diagnostics = append(run.Diagnostics, diag)
+		case msg.TestFile != "":
+			file := o.file(msg.TestFile)
+			file.Diagnostics = append(file.Diagnostics, diag)
+		}
+	}
+}
+
+func (o *TestOutput) file(path string) *TestFile {
+	for _, file := range o.report.Files {
+		if file.Path == path {
+			return file
+		}
+	}
+
+	file := &TestFile{Path: path}
+	o.report.Files = append(o.report.Files, file)
+
+	return file
+}
+
+func (o *TestOutput) run(path, name string) *TestRun {
+	file := o.file(path)
+
+	for _, run := range file.Runs {
+		if run.Name == name {
+			return run
+		}
+	}
+
+	run := &TestRun{Name: name}
+	file.Runs = append(file.Runs, run)
+
+	return run
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Errors   int               `xml:"errors,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Errors    int              `xml:"errors,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Cases     []*junitTestCase `xml:"testcase"`
+	SystemErr string           `xml:"system-err,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit returns the test report in JUnit XML format.
+func (r *TestReport) JUnit() ([]byte, error) {
+	suites := &junitTestSuites{Name: "tofu test"}
+
+	for _, file := range r.Files {
+		suite := &junitTestSuite{
+			Name:      file.Path,
+			SystemErr: strings.Join(file.Diagnostics, "\n\n"),
+		}
+
+		for _, run := range file.Runs {
+			testCase := &junitTestCase{Name: run.Name, ClassName: file.Path}
+			message := &junitMessage{
+				Message: fmt.Sprintf("run %q: %s", run.Name, run.Status),
+				Text:    strings.Join(run.Diagnostics, "\n\n"),
+			}
+
+			switch run.Status {
+			case TestStatusFail:
+				testCase.Failure = message
+				suite.Failures++
+			case TestStatusError:
+				testCase.Error = message
+				suite.Errors++
+			case TestStatusSkip:
+				testCase.Skipped = message
+				suite.Skipped++
+			}
+
+			suite.Tests++
+			suite.Cases = append(suite.Cases, testCase)
+		}
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	out, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
@@ -0,0 +1,151 @@
+package tofu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTofu_Test(t *testing.T) {
+	tests := []struct {
+		name       string
+		tofu       *Tofu
+		jsonOutput bool
+		want       []string
+	}{
+		{
+			name: "test with no options",
+			tofu: &Tofu{},
+			want: []string{
+				TofuBin,
+				"test",
+			},
+		},
+		{
+			name:       "test with json output",
+			tofu:       &Tofu{},
+			jsonOutput: true,
+			want: []string{
+				TofuBin,
+				"test",
+				"-json",
+			},
+		},
+		{
+			name: "test with options",
+			tofu: &Tofu{
+				TestOptions: TestOptions{
+					Filter:        []string{"tests/a.tftest.hcl", "tests/b.tftest.hcl"},
+					TestDirectory: "tests",
+					Var:           map[string]string{"region": "eu-central-1", "env": "test"},
+					VarFile:       []string{"test.tfvars"},
+					Verbose:       boolPtr(true),
+				},
+			},
+			want: []string{
+				TofuBin,
+				"test",
+				"-filter=tests/a.tftest.hcl",
+				"-filter=tests/b.tftest.hcl",
+				"-test-directory=tests",
+				"-var", "env=test",
+				"-var", "region=eu-central-1",
+				"-var-file=test.tfvars",
+				"-verbose",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.Test(tt.jsonOutput)
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestTestOutput(t *testing.T) {
+	lines := []string{
+		`{"@level":"info","@message":"Found 1 file and 2 run blocks","type":"test_abstract"}`,
+		`{"@level":"info","@message":"main.tftest.hcl... in progress","@testfile":"main.tftest.hcl",` +
+			`"test_file":{"path":"main.tftest.hcl","status":"pending"},"type":"test_file"}`,
+		`{"@level":"info","@message":"  run \"first\"... pass","@testfile":"main.tftest.hcl","@testrun":"first",` +
+			`"test_run":{"path":"main.tftest.hcl","run":"first","status":"pass"},"type":"test_run"}`,
+		`{"@level":"info","@message":"  run \"second\"... fail","@testfile":"main.tftest.hcl","@testrun":"second",` +
+			`"test_run":{"path":"main.tftest.hcl","run":"second","status":"fail"},"type":"test_run"}`,
+		`{"@level":"error","@message":"Error: Test assertion failed","@testfile":"main.tftest.hcl",` +
+			`"@testrun":"second","diagnostic":{"severity":"error","summary":"Test assertion failed",` +
+			`"detail":"bucket name mismatch"},"type":"diagnostic"}`,
+		`{"@level":"info","@message":"main.tftest.hcl... fail","@testfile":"main.tftest.hcl",` +
+			`"test_file":{"path":"main.tftest.hcl","status":"fail"},"type":"test_file"}`,
+		`{"@level":"info","@message":"Failure! 1 passed, 1 failed.","type":"test_summary"}`,
+	}
+
+	var out bytes.Buffer
+
+	output := NewTestOutput(&out)
+
+	for _, line := range lines {
+		// Write in two chunks to verify partial lines are buffered
+		half := len(line) / 2
+		_, _ = output.Write([]byte(line[:half]))
+		_, _ = output.Write([]byte(line[half:] + "\n"))
+	}
+
+	report := output.Report()
+
+	assert.Equal(t, "Found 1 file and 2 run blocks\n"+
+		"main.tftest.hcl... in progress\n"+
+		"  run \"first\"... pass\n"+
+		"  run \"second\"... fail\n"+
+		"Error: Test assertion failed\n"+
+		"bucket name mismatch\n"+
+		"main.tftest.hcl... fail\n"+
+		"Failure! 1 passed, 1 failed.\n", out.String())
+
+	require.Len(t, report.Files, 1)
+	assert.Equal(t, &TestFile{
+		Path:   "main.tftest.hcl",
+		Status: TestStatusFail,
+		Runs: []*TestRun{
+			{Name: "first", Status: TestStatusPass},
+			{Name: "second", Status: TestStatusFail, Diagnostics: []string{"Test assertion failed\n\nbucket name mismatch"}},
+		},
+	}, report.Files[0])
+}
+
+func TestTestReport_JUnit(t *testing.T) {
+	report := &TestReport{
+		Files: []*TestFile{
+			{
+				Path:   "main.tftest.hcl",
+				Status: TestStatusFail,
+				Runs: []*TestRun{
+					{Name: "first", Status: TestStatusPass},
+					{Name: "second", Status: TestStatusFail, Diagnostics: []string{"Test assertion failed"}},
+					{Name: "third", Status: TestStatusSkip},
+				},
+			},
+		},
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="tofu test" tests="3" failures="1" errors="0" skipped="1">
+  <testsuite name="main.tftest.hcl" tests="3" failures="1" errors="0" skipped="1">
+    <testcase name="first" classname="main.tftest.hcl"></testcase>
+    <testcase name="second" classname="main.tftest.hcl">
+      <failure message="run &#34;second&#34;: fail">Test assertion failed</failure>
+    </testcase>
+    <testcase name="third" classname="main.tftest.hcl">
+      <skipped message="run &#34;third&#34;: skip"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+
+	got, err := report.JUnit()
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+}
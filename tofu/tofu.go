@@ -2,22 +2,47 @@ package tofu
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
 	plugin_exec "github.com/thegeeklab/wp-plugin-go/v6/exec"
 )
 
-const TofuBin = "/usr/local/bin/tofu"
+const (
+	TofuBin  = "/usr/local/bin/tofu"
+	LockFile = ".terraform.lock.hcl"
+)
 
 type Tofu struct {
-	InitOptions InitOptions
-	FmtOptions  FmtOptions
+	InitOptions  InitOptions
+	FmtOptions   FmtOptions
+	TestOptions  TestOptions
+	StateOptions StateOptions
 
 	OutFile     string
 	Parallelism int64
 	Targets     []string
+	Replace     []string
 	Refresh     bool
 	NoLog       bool
+	Platforms   []string
+	Imports     []ImportTarget
+
+	// Env holds additional environment variables passed to every command.
+	Env []string
+	// JSONOutput enables machine readable output of plan and apply commands.
+	JSONOutput bool
+	// Stdout and Stderr receive the output of all commands printed to the console.
+	// If not set, the output is written to os.Stdout and os.Stderr.
+	Stdout io.Writer
+	Stderr io.Writer
+	// GracePeriod is the time a command has to exit after it was interrupted on
+	// cancellation before it is killed.
+	GracePeriod time.Duration
+	// Retry defines how commands failing with a transient error are retried.
+	Retry RetryPolicy
 }
 
 // InitOptions include options for the OpenTofu init command.
@@ -29,20 +54,42 @@ type InitOptions struct {
 	Lockfile      string   `json:"lockfile"`
 }
 
+// ImportTarget is an existing resource to import into the state.
+type ImportTarget struct {
+	Address string `json:"address"`
+	ID      string `json:"id"`
+}
+
+// StateOptions include options for the OpenTofu state commands.
+type StateOptions struct {
+	Move   []StateMove `json:"move"`
+	Remove []string    `json:"remove"`
+	File   string      `json:"file"`
+	DryRun bool        `json:"dry-run"`
+	Force  bool        `json:"force"`
+}
+
+// StateMove is a resource address to move in the state.
+type StateMove struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
 // FmtOptions fmt options for the OpenTofu fmt command.
 type FmtOptions struct {
-	List  *bool `json:"list"`
-	Write *bool `json:"write"`
-	Diff  *bool `json:"diff"`
-	Check *bool `json:"check"`
+	List      *bool `json:"list"`
+	Write     *bool `json:"write"`
+	Diff      *bool `json:"diff"`
+	Check     *bool `json:"check"`
+	Recursive *bool `json:"recursive"`
 }
 
 func (t *Tofu) Version() *plugin_exec.Cmd {
-	cmd := plugin_exec.Command(TofuBin, "version")
+	cmd := t.command("version")
 
 	if !t.NoLog {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = t.stdout()
+		cmd.Stderr = t.stderr()
 	}
 
 	return cmd
@@ -76,25 +123,33 @@ func (t *Tofu) Init() *plugin_exec.Cmd {
 	// Fail tofu execution on prompt
 	args = append(args, "-input=false")
 
-	cmd := plugin_exec.Command(TofuBin, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
 
 	return cmd
 }
 
 func (t *Tofu) GetModules() *plugin_exec.Cmd {
-	cmd := plugin_exec.Command(TofuBin, "get")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := t.command("get")
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
 
 	return cmd
 }
 
-func (t *Tofu) Validate() *plugin_exec.Cmd {
-	cmd := plugin_exec.Command(TofuBin, "validate")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func (t *Tofu) Validate(jsonOutput bool) *plugin_exec.Cmd {
+	args := []string{
+		"validate",
+	}
+
+	if jsonOutput {
+		args = append(args, "-json")
+	}
+
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
 
 	return cmd
 }
@@ -120,9 +175,13 @@ func (t *Tofu) Fmt() *plugin_exec.Cmd {
 		args = append(args, fmt.Sprintf("-check=%t", *t.FmtOptions.Check))
 	}
 
-	cmd := plugin_exec.Command(TofuBin, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	if t.FmtOptions.Recursive != nil {
+		args = append(args, fmt.Sprintf("-recursive=%t", *t.FmtOptions.Recursive))
+	}
+
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
 
 	return cmd
 }
@@ -142,6 +201,12 @@ func (t *Tofu) Plan(destroy bool) *plugin_exec.Cmd {
 		args = append(args, "--target", value)
 	}
 
+	if !destroy {
+		for _, value := range t.Replace {
+			args = append(args, fmt.Sprintf("-replace=%s", value))
+		}
+	}
+
 	if t.Parallelism > 0 {
 		args = append(args, fmt.Sprintf("-parallelism=%d", t.Parallelism))
 	}
@@ -158,11 +223,82 @@ func (t *Tofu) Plan(destroy bool) *plugin_exec.Cmd {
 		args = append(args, "-refresh=false")
 	}
 
-	cmd := plugin_exec.Command(TofuBin, args...)
+	if t.JSONOutput {
+		args = append(args, "-json")
+	}
+
+	cmd := t.command(args...)
 
 	if !t.NoLog {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = t.stdout()
+		cmd.Stderr = t.stderr()
+	}
+
+	return cmd
+}
+
+// PlanRefreshOnly creates a refresh-only plan, which updates the state to match
+// remote objects without proposing changes. The plan is saved to the output file.
+func (t *Tofu) PlanRefreshOnly() *plugin_exec.Cmd {
+	args := []string{
+		"plan",
+		"-refresh-only",
+	}
+
+	if t.OutFile != "" {
+		args = append(args, fmt.Sprintf("-out=%s", t.OutFile))
+	}
+
+	for _, value := range t.Targets {
+		args = append(args, "--target", value)
+	}
+
+	if t.Parallelism > 0 {
+		args = append(args, fmt.Sprintf("-parallelism=%d", t.Parallelism))
+	}
+
+	args = append(args, t.lockArgs()...)
+
+	if t.JSONOutput {
+		args = append(args, "-json")
+	}
+
+	cmd := t.command(args...)
+
+	if !t.NoLog {
+		cmd.Stdout = t.stdout()
+		cmd.Stderr = t.stderr()
+	}
+
+	return cmd
+}
+
+// ApplyRefreshOnly applies a saved refresh-only plan.
+func (t *Tofu) ApplyRefreshOnly() *plugin_exec.Cmd {
+	args := []string{
+		"apply",
+	}
+
+	if t.Parallelism > 0 {
+		args = append(args, fmt.Sprintf("-parallelism=%d", t.Parallelism))
+	}
+
+	args = append(args, t.lockArgs()...)
+
+	if t.JSONOutput {
+		args = append(args, "-json")
+	}
+
+	// The saved plan is positional, flags after it are not parsed
+	if t.OutFile != "" {
+		args = append(args, t.OutFile)
+	}
+
+	cmd := t.command(args...)
+
+	if !t.NoLog {
+		cmd.Stdout = t.stdout()
+		cmd.Stderr = t.stderr()
 	}
 
 	return cmd
@@ -177,6 +313,13 @@ func (t *Tofu) Apply() *plugin_exec.Cmd {
 		args = append(args, "--target", v)
 	}
 
+	// Replacements are part of a saved plan and only apply without one
+	if t.OutFile == "" {
+		for _, v := range t.Replace {
+			args = append(args, fmt.Sprintf("-replace=%s", v))
+		}
+	}
+
 	if t.Parallelism > 0 {
 		args = append(args, fmt.Sprintf("-parallelism=%d", t.Parallelism))
 	}
@@ -193,15 +336,20 @@ func (t *Tofu) Apply() *plugin_exec.Cmd {
 		args = append(args, "-refresh=false")
 	}
 
+	if t.JSONOutput {
+		args = append(args, "-json")
+	}
+
+	// The saved plan is positional, flags after it are not parsed
 	if t.OutFile != "" {
 		args = append(args, t.OutFile)
 	}
 
-	cmd := plugin_exec.Command(TofuBin, args...)
+	cmd := t.command(args...)
 
 	if !t.NoLog {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = t.stdout()
+		cmd.Stderr = t.stderr()
 	}
 
 	return cmd
@@ -228,13 +376,223 @@ func (t *Tofu) Destroy() *plugin_exec.Cmd {
 		args = append(args, fmt.Sprintf("-lock-timeout=%s", t.InitOptions.LockTimeout))
 	}
 
+	if !t.Refresh {
+		args = append(args, "-refresh=false")
+	}
+
 	args = append(args, "-auto-approve")
 
-	cmd := plugin_exec.Command(TofuBin, args...)
+	if t.JSONOutput {
+		args = append(args, "-json")
+	}
+
+	cmd := t.command(args...)
+
+	if !t.NoLog {
+		cmd.Stdout = t.stdout()
+		cmd.Stderr = t.stderr()
+	}
+
+	return cmd
+}
+
+func (t *Tofu) StateList() *plugin_exec.Cmd {
+	cmd := t.command("state", "list")
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+// NoStateFound reports whether the stderr of a state command reports that no state
+// exists yet.
+func NoStateFound(stderr string) bool {
+	return strings.Contains(stderr, "No state file was found")
+}
+
+func (t *Tofu) StateMove(move StateMove) *plugin_exec.Cmd {
+	args := []string{
+		"state",
+		"mv",
+	}
+
+	if t.StateOptions.DryRun {
+		args = append(args, "-dry-run")
+	}
+
+	args = append(args, t.lockArgs()...)
+	args = append(args, move.Source, move.Destination)
+
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+func (t *Tofu) StateRemove() *plugin_exec.Cmd {
+	args := []string{
+		"state",
+		"rm",
+	}
+
+	if t.StateOptions.DryRun {
+		args = append(args, "-dry-run")
+	}
+
+	args = append(args, t.lockArgs()...)
+	args = append(args, t.StateOptions.Remove...)
+
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+// StatePull returns the command to pull the state. The state is written to stdout
+// of the command, which has to be set by the caller.
+func (t *Tofu) StatePull() *plugin_exec.Cmd {
+	cmd := t.command("state", "pull")
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+func (t *Tofu) StatePush(path string) *plugin_exec.Cmd {
+	args := []string{
+		"state",
+		"push",
+	}
+
+	if t.StateOptions.Force {
+		args = append(args, "-force")
+	}
+
+	args = append(args, t.lockArgs()...)
+	args = append(args, path)
+
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+func (t *Tofu) Import(target ImportTarget) *plugin_exec.Cmd {
+	args := []string{
+		"import",
+	}
+
+	if t.Parallelism > 0 {
+		args = append(args, fmt.Sprintf("-parallelism=%d", t.Parallelism))
+	}
+
+	if t.InitOptions.Lock != nil {
+		args = append(args, fmt.Sprintf("-lock=%t", *t.InitOptions.Lock))
+	}
+
+	if t.InitOptions.LockTimeout != "" {
+		args = append(args, fmt.Sprintf("-lock-timeout=%s", t.InitOptions.LockTimeout))
+	}
+
+	// Fail tofu execution on prompt
+	args = append(args, "-input=false", target.Address, target.ID)
+
+	cmd := t.command(args...)
 
 	if !t.NoLog {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = t.stdout()
+		cmd.Stderr = t.stderr()
+	}
+
+	return cmd
+}
+
+func (t *Tofu) ProvidersLock() *plugin_exec.Cmd {
+	args := []string{
+		"providers",
+		"lock",
+	}
+
+	for _, platform := range t.Platforms {
+		args = append(args, fmt.Sprintf("-platform=%s", platform))
+	}
+
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+// ProvidersMirror returns a command downloading the required providers for all
+// configured platforms into the mirror dir.
+func (t *Tofu) ProvidersMirror(dir string) *plugin_exec.Cmd {
+	args := []string{
+		"providers",
+		"mirror",
+	}
+
+	for _, platform := range t.Platforms {
+		args = append(args, fmt.Sprintf("-platform=%s", platform))
+	}
+
+	args = append(args, dir)
+
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+// Command returns a command running an arbitrary tofu subcommand with the given args.
+func (t *Tofu) Command(args []string) *plugin_exec.Cmd {
+	cmd := t.command(args...)
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+
+	return cmd
+}
+
+func (t *Tofu) stdout() io.Writer {
+	if t.Stdout != nil {
+		return t.Stdout
+	}
+
+	return os.Stdout
+}
+
+func (t *Tofu) stderr() io.Writer {
+	if t.Stderr != nil {
+		return t.Stderr
+	}
+
+	return os.Stderr
+}
+
+// lockArgs returns the state lock arguments.
+func (t *Tofu) lockArgs() []string {
+	args := make([]string, 0)
+
+	if t.InitOptions.Lock != nil {
+		args = append(args, fmt.Sprintf("-lock=%t", *t.InitOptions.Lock))
+	}
+
+	if t.InitOptions.LockTimeout != "" {
+		args = append(args, fmt.Sprintf("-lock-timeout=%s", t.InitOptions.LockTimeout))
+	}
+
+	return args
+}
+
+// command creates a tofu command with the additional environment applied.
+func (t *Tofu) command(args ...string) *plugin_exec.Cmd {
+	cmd := plugin_exec.Command(TofuBin, args...)
+
+	if len(t.Env) > 0 {
+		cmd.Env = append(os.Environ(), t.Env...)
 	}
 
 	return cmd
@@ -108,20 +108,27 @@ func TestTofu_GetModules(t *testing.T) {
 
 func TestTofu_Validate(t *testing.T) {
 	tests := []struct {
-		name string
-		tofu *Tofu
-		want []string
+		name       string
+		tofu       *Tofu
+		jsonOutput bool
+		want       []string
 	}{
 		{
 			name: "validate command",
 			tofu: &Tofu{},
 			want: []string{TofuBin, "validate"},
 		},
+		{
+			name:       "validate with json output",
+			tofu:       &Tofu{},
+			jsonOutput: true,
+			want:       []string{TofuBin, "validate", "-json"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := tt.tofu.Validate()
+			cmd := tt.tofu.Validate(tt.jsonOutput)
 			assert.Equal(t, tt.want, cmd.Args)
 		})
 	}
@@ -193,6 +200,19 @@ func TestTofu_Fmt(t *testing.T) {
 				"-check=true",
 			},
 		},
+		{
+			name: "fmt with recursive option",
+			tofu: &Tofu{
+				FmtOptions: FmtOptions{
+					Recursive: boolPtr(true),
+				},
+			},
+			want: []string{
+				TofuBin,
+				"fmt",
+				"-recursive=true",
+			},
+		},
 		{
 			name: "fmt with multiple options",
 			tofu: &Tofu{
@@ -239,6 +259,19 @@ func TestTofu_Plan(t *testing.T) {
 				"-refresh=false",
 			},
 		},
+		{
+			name: "plan with json output",
+			tofu: &Tofu{
+				JSONOutput: true,
+			},
+			destroy: false,
+			want: []string{
+				TofuBin,
+				"plan",
+				"-refresh=false",
+				"-json",
+			},
+		},
 		{
 			name: "plan with output options",
 			tofu: &Tofu{
@@ -277,6 +310,33 @@ func TestTofu_Plan(t *testing.T) {
 				"-refresh=false",
 			},
 		},
+		{
+			name: "plan with replace",
+			tofu: &Tofu{
+				Replace: []string{"aws_instance.web[0]", "aws_instance.db"},
+			},
+			destroy: false,
+			want: []string{
+				TofuBin,
+				"plan",
+				"-replace=aws_instance.web[0]",
+				"-replace=aws_instance.db",
+				"-refresh=false",
+			},
+		},
+		{
+			name: "plan destroy ignores replace",
+			tofu: &Tofu{
+				Replace: []string{"aws_instance.web[0]"},
+			},
+			destroy: true,
+			want: []string{
+				TofuBin,
+				"plan",
+				"-destroy",
+				"-refresh=false",
+			},
+		},
 		{
 			name: "plan with parallelism",
 			tofu: &Tofu{
@@ -356,6 +416,20 @@ func TestTofu_Apply(t *testing.T) {
 				"-refresh=false",
 			},
 		},
+		{
+			name: "apply saved plan with json output",
+			tofu: &Tofu{
+				Refresh:    true,
+				OutFile:    "plan.tfout",
+				JSONOutput: true,
+			},
+			want: []string{
+				TofuBin,
+				"apply",
+				"-json",
+				"plan.tfout",
+			},
+		},
 		{
 			name: "apply with targets",
 			tofu: &Tofu{
@@ -369,6 +443,31 @@ func TestTofu_Apply(t *testing.T) {
 				"-refresh=false",
 			},
 		},
+		{
+			name: "apply with replace",
+			tofu: &Tofu{
+				Replace: []string{"aws_instance.web[0]"},
+			},
+			want: []string{
+				TofuBin,
+				"apply",
+				"-replace=aws_instance.web[0]",
+				"-refresh=false",
+			},
+		},
+		{
+			name: "apply saved plan ignores replace",
+			tofu: &Tofu{
+				Replace: []string{"aws_instance.web[0]"},
+				OutFile: "out.tfout",
+			},
+			want: []string{
+				TofuBin,
+				"apply",
+				"-refresh=false",
+				"out.tfout",
+			},
+		},
 		{
 			name: "apply with parallelism",
 			tofu: &Tofu{
@@ -465,6 +564,18 @@ func TestTofu_Destroy(t *testing.T) {
 		{
 			name: "destroy with no options",
 			tofu: &Tofu{},
+			want: []string{
+				TofuBin,
+				"destroy",
+				"-refresh=false",
+				"-auto-approve",
+			},
+		},
+		{
+			name: "destroy with refresh",
+			tofu: &Tofu{
+				Refresh: true,
+			},
 			want: []string{
 				TofuBin,
 				"destroy",
@@ -481,6 +592,7 @@ func TestTofu_Destroy(t *testing.T) {
 				"destroy",
 				"-target=target1",
 				"-target=target2",
+				"-refresh=false",
 				"-auto-approve",
 			},
 		},
@@ -493,6 +605,7 @@ func TestTofu_Destroy(t *testing.T) {
 				TofuBin,
 				"destroy",
 				"-parallelism=10",
+				"-refresh=false",
 				"-auto-approve",
 			},
 		},
@@ -507,6 +620,7 @@ func TestTofu_Destroy(t *testing.T) {
 				TofuBin,
 				"destroy",
 				"-lock=true",
+				"-refresh=false",
 				"-auto-approve",
 			},
 		},
@@ -521,6 +635,7 @@ func TestTofu_Destroy(t *testing.T) {
 				TofuBin,
 				"destroy",
 				"-lock-timeout=10s",
+				"-refresh=false",
 				"-auto-approve",
 			},
 		},
@@ -532,6 +647,7 @@ func TestTofu_Destroy(t *testing.T) {
 			want: []string{
 				TofuBin,
 				"destroy",
+				"-refresh=false",
 				"-auto-approve",
 			},
 		},
@@ -549,3 +665,352 @@ func TestTofu_Destroy(t *testing.T) {
 		})
 	}
 }
+
+func TestTofu_ProvidersLock(t *testing.T) {
+	tests := []struct {
+		name string
+		tofu *Tofu
+		want []string
+	}{
+		{
+			name: "providers lock with no platforms",
+			tofu: &Tofu{},
+			want: []string{
+				TofuBin,
+				"providers",
+				"lock",
+			},
+		},
+		{
+			name: "providers lock with platforms",
+			tofu: &Tofu{
+				Platforms: []string{"linux_amd64", "darwin_arm64"},
+			},
+			want: []string{
+				TofuBin,
+				"providers",
+				"lock",
+				"-platform=linux_amd64",
+				"-platform=darwin_arm64",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.ProvidersLock()
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestTofu_ProvidersMirror(t *testing.T) {
+	cmd := (&Tofu{Platforms: []string{"linux_amd64", "linux_arm64"}}).ProvidersMirror("/opt/providers")
+	assert.Equal(t, []string{
+		TofuBin,
+		"providers",
+		"mirror",
+		"-platform=linux_amd64",
+		"-platform=linux_arm64",
+		"/opt/providers",
+	}, cmd.Args)
+}
+
+func TestTofu_StateList(t *testing.T) {
+	cmd := (&Tofu{}).StateList()
+	assert.Equal(t, []string{TofuBin, "state", "list"}, cmd.Args)
+}
+
+func TestTofu_Import(t *testing.T) {
+	tests := []struct {
+		name   string
+		tofu   *Tofu
+		target ImportTarget
+		want   []string
+	}{
+		{
+			name:   "import with no options",
+			tofu:   &Tofu{},
+			target: ImportTarget{Address: "aws_s3_bucket.logs", ID: "logs-bucket"},
+			want: []string{
+				TofuBin,
+				"import",
+				"-input=false",
+				"aws_s3_bucket.logs",
+				"logs-bucket",
+			},
+		},
+		{
+			name: "import with options",
+			tofu: &Tofu{
+				Parallelism: 5,
+				InitOptions: InitOptions{
+					Lock:        boolPtr(true),
+					LockTimeout: "10s",
+				},
+			},
+			target: ImportTarget{Address: `module.db.aws_db_instance.this["main"]`, ID: "db-1"},
+			want: []string{
+				TofuBin,
+				"import",
+				"-parallelism=5",
+				"-lock=true",
+				"-lock-timeout=10s",
+				"-input=false",
+				`module.db.aws_db_instance.this["main"]`,
+				"db-1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.Import(tt.target)
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestTofu_StateMove(t *testing.T) {
+	tests := []struct {
+		name string
+		tofu *Tofu
+		move StateMove
+		want []string
+	}{
+		{
+			name: "state mv",
+			tofu: &Tofu{},
+			move: StateMove{Source: "aws_instance.a", Destination: "module.app.aws_instance.a"},
+			want: []string{
+				TofuBin,
+				"state",
+				"mv",
+				"aws_instance.a",
+				"module.app.aws_instance.a",
+			},
+		},
+		{
+			name: "state mv with dry run and lock options",
+			tofu: &Tofu{
+				StateOptions: StateOptions{DryRun: true},
+				InitOptions:  InitOptions{Lock: boolPtr(true), LockTimeout: "10s"},
+			},
+			move: StateMove{Source: "aws_instance.a", Destination: "aws_instance.b"},
+			want: []string{
+				TofuBin,
+				"state",
+				"mv",
+				"-dry-run",
+				"-lock=true",
+				"-lock-timeout=10s",
+				"aws_instance.a",
+				"aws_instance.b",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.StateMove(tt.move)
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestTofu_StateRemove(t *testing.T) {
+	tests := []struct {
+		name string
+		tofu *Tofu
+		want []string
+	}{
+		{
+			name: "state rm with multiple addresses",
+			tofu: &Tofu{
+				StateOptions: StateOptions{Remove: []string{"aws_instance.a", "aws_instance.b"}},
+			},
+			want: []string{
+				TofuBin,
+				"state",
+				"rm",
+				"aws_instance.a",
+				"aws_instance.b",
+			},
+		},
+		{
+			name: "state rm with dry run",
+			tofu: &Tofu{
+				StateOptions: StateOptions{Remove: []string{"aws_instance.a"}, DryRun: true},
+			},
+			want: []string{
+				TofuBin,
+				"state",
+				"rm",
+				"-dry-run",
+				"aws_instance.a",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.StateRemove()
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestTofu_StatePull(t *testing.T) {
+	cmd := (&Tofu{}).StatePull()
+	assert.Equal(t, []string{TofuBin, "state", "pull"}, cmd.Args)
+	assert.Nil(t, cmd.Stdout)
+}
+
+func TestTofu_StatePush(t *testing.T) {
+	tests := []struct {
+		name string
+		tofu *Tofu
+		want []string
+	}{
+		{
+			name: "state push",
+			tofu: &Tofu{},
+			want: []string{
+				TofuBin,
+				"state",
+				"push",
+				"/tmp/state.tfstate",
+			},
+		},
+		{
+			name: "state push with force and lock timeout",
+			tofu: &Tofu{
+				StateOptions: StateOptions{Force: true},
+				InitOptions:  InitOptions{LockTimeout: "10s"},
+			},
+			want: []string{
+				TofuBin,
+				"state",
+				"push",
+				"-force",
+				"-lock-timeout=10s",
+				"/tmp/state.tfstate",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.StatePush("/tmp/state.tfstate")
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestTofu_PlanRefreshOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		tofu *Tofu
+		want []string
+	}{
+		{
+			name: "refresh only plan with output file",
+			tofu: &Tofu{
+				OutFile: "plan.tfout",
+			},
+			want: []string{
+				TofuBin,
+				"plan",
+				"-refresh-only",
+				"-out=plan.tfout",
+			},
+		},
+		{
+			name: "refresh only plan with options",
+			tofu: &Tofu{
+				OutFile:     "plan.tfout",
+				Targets:     []string{"target1"},
+				Parallelism: 10,
+				InitOptions: InitOptions{
+					Lock: boolPtr(false),
+				},
+			},
+			want: []string{
+				TofuBin,
+				"plan",
+				"-refresh-only",
+				"-out=plan.tfout",
+				"--target", "target1",
+				"-parallelism=10",
+				"-lock=false",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.PlanRefreshOnly()
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestTofu_ApplyRefreshOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		tofu *Tofu
+		want []string
+	}{
+		{
+			name: "apply refresh only plan",
+			tofu: &Tofu{
+				OutFile: "plan.tfout",
+			},
+			want: []string{
+				TofuBin,
+				"apply",
+				"plan.tfout",
+			},
+		},
+		{
+			name: "apply refresh only plan with json output",
+			tofu: &Tofu{
+				OutFile:    "plan.tfout",
+				JSONOutput: true,
+			},
+			want: []string{
+				TofuBin,
+				"apply",
+				"-json",
+				"plan.tfout",
+			},
+		},
+		{
+			name: "apply refresh only plan with lock timeout",
+			tofu: &Tofu{
+				OutFile: "plan.tfout",
+				InitOptions: InitOptions{
+					LockTimeout: "10s",
+				},
+			},
+			want: []string{
+				TofuBin,
+				"apply",
+				"-lock-timeout=10s",
+				"plan.tfout",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.tofu.ApplyRefreshOnly()
+			assert.Equal(t, tt.want, cmd.Args)
+		})
+	}
+}
+
+func TestNoStateFound(t *testing.T) {
+	assert.True(t, NoStateFound("No state file was found!\n\nState management commands require a state file."))
+	assert.False(t, NoStateFound("Error: Failed to load state: AccessDenied"))
+}
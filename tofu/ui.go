@@ -0,0 +1,306 @@
+package tofu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Message types of the machine readable UI.
+const (
+	UIMessageVersion       = "version"
+	UIMessageDiagnostic    = "diagnostic"
+	UIMessagePlannedChange = "planned_change"
+	UIMessageResourceDrift = "resource_drift"
+	UIMessageChangeSummary = "change_summary"
+	UIMessageApplyStart    = "apply_start"
+	UIMessageApplyProgress = "apply_progress"
+	UIMessageApplyComplete = "apply_complete"
+	UIMessageApplyErrored  = "apply_errored"
+	UIMessageOutputs       = "outputs"
+)
+
+const (
+	DiagnosticSeverityError   = "error"
+	DiagnosticSeverityWarning = "warning"
+)
+
+// UIEvent is a message of the machine readable UI of plan and apply commands.
+//
+//nolint:tagliatelle
+type UIEvent struct {
+	Type       string                 `json:"type"`
+	Level      string                 `json:"@level"`
+	Message    string                 `json:"@message"`
+	Diagnostic *Diagnostic            `json:"diagnostic"`
+	Change     *ResourceChange        `json:"change"`
+	Hook       *ResourceHook          `json:"hook"`
+	Changes    *ChangeSummary         `json:"changes"`
+	Outputs    map[string]OutputValue `json:"outputs"`
+}
+
+// Diagnostic is an error or warning reported by OpenTofu.
+type Diagnostic struct {
+	Severity string           `json:"severity"`
+	Summary  string           `json:"summary"`
+	Detail   string           `json:"detail"`
+	Address  string           `json:"address"`
+	Range    *DiagnosticRange `json:"range"`
+}
+
+// DiagnosticRange is the source location of a diagnostic.
+type DiagnosticRange struct {
+	Filename string        `json:"filename"`
+	Start    DiagnosticPos `json:"start"`
+	End      DiagnosticPos `json:"end"`
+}
+
+// DiagnosticPos is a position in a source file.
+type DiagnosticPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// ResourceAddr identifies the resource of a change or hook event.
+type ResourceAddr struct {
+	Addr   string `json:"addr"`
+	Module string `json:"module"`
+}
+
+// ResourceChange is a planned change or a detected drift of a resource.
+type ResourceChange struct {
+	Resource ResourceAddr `json:"resource"`
+	Action   string       `json:"action"`
+	Reason   string       `json:"reason"`
+}
+
+// ResourceHook reports the progress of a resource operation during apply.
+//
+//nolint:tagliatelle
+type ResourceHook struct {
+	Resource ResourceAddr `json:"resource"`
+	Action   string       `json:"action"`
+	IDKey    string       `json:"id_key"`
+	IDValue  string       `json:"id_value"`
+	Elapsed  float64      `json:"elapsed_seconds"`
+}
+
+// ChangeSummary holds the number of resource changes of a plan or apply.
+type ChangeSummary struct {
+	Add       int    `json:"add"`
+	Change    int    `json:"change"`
+	Import    int    `json:"import"`
+	Remove    int    `json:"remove"`
+	Operation string `json:"operation"`
+}
+
+// OutputValue is a root module output. The value is only set after apply.
+type OutputValue struct {
+	Sensitive bool            `json:"sensitive"`
+	Type      json.RawMessage `json:"type"`
+	Value     json.RawMessage `json:"value"`
+	Action    string          `json:"action"`
+}
+
+// ValidateResult is the machine readable output of the validate command.
+//
+//nolint:tagliatelle
+type ValidateResult struct {
+	Valid        bool          `json:"valid"`
+	ErrorCount   int           `json:"error_count"`
+	WarningCount int           `json:"warning_count"`
+	Diagnostics  []*Diagnostic `json:"diagnostics"`
+}
+
+// UIOutput parses the machine readable output of plan and apply commands while it is
+// streamed and writes the human readable messages to the underlying writer.
+type UIOutput struct {
+	*lineWriter
+	out    io.Writer
+	events []*UIEvent
+}
+
+// NewUIOutput creates a UIOutput writing human readable messages to out.
+func NewUIOutput(out io.Writer) *UIOutput {
+	o := &UIOutput{
+		out: out,
+	}
+	o.lineWriter = newLineWriter(o.handle)
+
+	return o
+}
+
+// Events returns all parsed events.
+func (o *UIOutput) Events() []*UIEvent {
+	o.Flush()
+
+	return o.events
+}
+
+// Diagnostics returns all reported diagnostics.
+func (o *UIOutput) Diagnostics() []*Diagnostic {
+	diags := make([]*Diagnostic, 0)
+
+	for _, event := range o.Events() {
+		if event.Diagnostic != nil {
+			diags = append(diags, event.Diagnostic)
+		}
+	}
+
+	return diags
+}
+
+// ChangeSummary returns the last reported change summary or nil if there is none.
+func (o *UIOutput) ChangeSummary() *ChangeSummary {
+	var summary *ChangeSummary
+
+	for _, event := range o.Events() {
+		if event.Changes != nil {
+			summary = event.Changes
+		}
+	}
+
+	return summary
+}
+
+// Drift returns the changes made to resources outside of OpenTofu that were detected
+// while refreshing the state.
+func (o *UIOutput) Drift() []*ResourceChange {
+	changes := make([]*ResourceChange, 0)
+
+	for _, event := range o.Events() {
+		if event.Type == UIMessageResourceDrift && event.Change != nil {
+			changes = append(changes, event.Change)
+		}
+	}
+
+	return changes
+}
+
+// Failed returns the hooks of all failed resource operations.
+func (o *UIOutput) Failed() []*ResourceHook {
+	hooks := make([]*ResourceHook, 0)
+
+	for _, event := range o.Events() {
+		if event.Type == UIMessageApplyErrored && event.Hook != nil {
+			hooks = append(hooks, event.Hook)
+		}
+	}
+
+	return hooks
+}
+
+func (o *UIOutput) handle(line []byte) {
+	event := &UIEvent{}
+	if err := json.Unmarshal(line, event); err != nil || event.Type == "" {
+		fmt.Fprintln(o.out, string(line))
+
+		return
+	}
+
+	o.events = append(o.events, event)
+
+	switch {
+	case event.Diagnostic != nil:
+		RenderDiagnostic(o.out, event.Diagnostic)
+	case event.Type == UIMessageOutputs:
+		renderOutputs(o.out, event.Outputs)
+	default:
+		fmt.Fprintln(o.out, event.Message)
+	}
+}
+
+// DiagnosticWriter is an io.Writer that extracts the diagnostics from machine readable
+// output and writes them in human readable form, as OpenTofu prints them to stderr without
+// JSON output. All other lines are dropped.
+type DiagnosticWriter struct {
+	*lineWriter
+	out io.Writer
+}
+
+// NewDiagnosticWriter creates a DiagnosticWriter writing the diagnostics to out.
+func NewDiagnosticWriter(out io.Writer) *DiagnosticWriter {
+	w := &DiagnosticWriter{
+		out: out,
+	}
+	w.lineWriter = newLineWriter(w.handle)
+
+	return w
+}
+
+func (w *DiagnosticWriter) handle(line []byte) {
+	event := &UIEvent{}
+	if err := json.Unmarshal(line, event); err != nil || event.Diagnostic == nil {
+		return
+	}
+
+	RenderDiagnostic(w.out, event.Diagnostic)
+}
+
+// ParseValidateResult parses the machine readable output of the validate command.
+func ParseValidateResult(data []byte) (*ValidateResult, error) {
+	result := &ValidateResult{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal validate result: %w", err)
+	}
+
+	return result, nil
+}
+
+// RenderDiagnostic writes a diagnostic in human readable form.
+func RenderDiagnostic(w io.Writer, d *Diagnostic) {
+	severity := "Error"
+	if d.Severity == DiagnosticSeverityWarning {
+		severity = "Warning"
+	}
+
+	fmt.Fprintf(w, "%s: %s\n", severity, d.Summary)
+
+	if d.Range != nil {
+		fmt.Fprintf(w, "\n  on %s line %d", d.Range.Filename, d.Range.Start.Line)
+
+		if d.Address != "" {
+			fmt.Fprintf(w, ", in %s", d.Address)
+		}
+
+		fmt.Fprintln(w, ":")
+	}
+
+	if d.Detail != "" {
+		fmt.Fprintf(w, "\n%s\n", d.Detail)
+	}
+
+	fmt.Fprintln(w)
+}
+
+func renderOutputs(w io.Writer, outputs map[string]OutputValue) {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Outputs:")
+
+	for _, name := range names {
+		output := outputs[name]
+
+		switch {
+		case output.Sensitive:
+			fmt.Fprintf(w, "%s = (sensitive value)\n", name)
+		case len(output.Value) > 0:
+			var value bytes.Buffer
+			if err := json.Compact(&value, output.Value); err != nil {
+				value.Write(output.Value)
+			}
+
+			fmt.Fprintf(w, "%s = %s\n", name, value.String())
+		default:
+			fmt.Fprintf(w, "%s: %s\n", name, output.Action)
+		}
+	}
+}
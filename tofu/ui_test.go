@@ -0,0 +1,124 @@
+package tofu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUIOutput(t *testing.T) {
+	//nolint:lll
+	input := `{"@level":"info","@message":"OpenTofu 1.8.0","type":"version","terraform":"1.8.0","ui":"1.2"}
+{"@level":"info","@message":"aws_s3_bucket.logs: Drift detected (update)","type":"resource_drift","change":{"resource":{"addr":"aws_s3_bucket.logs","module":""},"action":"update"}}
+{"@level":"info","@message":"aws_instance.web: Plan to create","type":"planned_change","change":{"resource":{"addr":"aws_instance.web","module":""},"action":"create"}}
+{"@level":"info","@message":"Plan: 1 to add, 0 to change, 0 to destroy.","type":"change_summary","changes":{"add":1,"change":0,"import":0,"remove":0,"operation":"plan"}}
+{"@level":"info","@message":"aws_instance.web: Creating...","type":"apply_start","hook":{"resource":{"addr":"aws_instance.web","module":""},"action":"create"}}
+{"@level":"info","@message":"aws_instance.web: Creation errored after 2s","type":"apply_errored","hook":{"resource":{"addr":"aws_instance.web","module":""},"action":"create","elapsed_seconds":2}}
+{"@level":"error","@message":"Error: creating EC2 Instance","type":"diagnostic","diagnostic":{"severity":"error","summary":"creating EC2 Instance","detail":"InvalidAMIID.NotFound","address":"aws_instance.web","range":{"filename":"main.tf","start":{"line":3,"column":1,"byte":20},"end":{"line":3,"column":30,"byte":49}}}}
+{"@level":"info","@message":"Outputs: 2","type":"outputs","outputs":{"ip":{"sensitive":false,"type":"string","value":"10.0.0.1"},"password":{"sensitive":true,"type":"string"}}}
+not json
+`
+
+	var out bytes.Buffer
+
+	output := NewUIOutput(&out)
+	_, err := output.Write([]byte(input))
+	require.NoError(t, err)
+
+	events := output.Events()
+	require.Len(t, events, 8)
+	assert.Equal(t, UIMessagePlannedChange, events[2].Type)
+	assert.Equal(t, &ResourceChange{Resource: ResourceAddr{Addr: "aws_instance.web"}, Action: "create"}, events[2].Change)
+
+	drift := output.Drift()
+	require.Len(t, drift, 1)
+	assert.Equal(t, "aws_s3_bucket.logs", drift[0].Resource.Addr)
+
+	assert.Equal(t, &ChangeSummary{Add: 1, Operation: "plan"}, output.ChangeSummary())
+
+	failed := output.Failed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, "aws_instance.web", failed[0].Resource.Addr)
+	assert.InDelta(t, 2.0, failed[0].Elapsed, 0)
+
+	diags := output.Diagnostics()
+	require.Len(t, diags, 1)
+	assert.Equal(t, DiagnosticSeverityError, diags[0].Severity)
+	assert.Equal(t, 3, diags[0].Range.Start.Line)
+
+	assert.Equal(t, `OpenTofu 1.8.0
+aws_s3_bucket.logs: Drift detected (update)
+aws_instance.web: Plan to create
+Plan: 1 to add, 0 to change, 0 to destroy.
+aws_instance.web: Creating...
+aws_instance.web: Creation errored after 2s
+Error: creating EC2 Instance
+
+  on main.tf line 3, in aws_instance.web:
+
+InvalidAMIID.NotFound
+
+Outputs:
+ip = "10.0.0.1"
+password = (sensitive value)
+not json
+`, out.String())
+}
+
+func TestParseValidateResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    *ValidateResult
+		wantErr bool
+	}{
+		{
+			name:  "valid configuration",
+			input: `{"format_version":"1.0","valid":true,"error_count":0,"warning_count":0,"diagnostics":[]}`,
+			want:  &ValidateResult{Valid: true, Diagnostics: []*Diagnostic{}},
+		},
+		{
+			name: "invalid configuration",
+			input: `{"format_version":"1.0","valid":false,"error_count":1,"warning_count":0,"diagnostics":[` +
+				`{"severity":"error","summary":"Unsupported argument","detail":"An argument named \"foo\" is not expected here.",` +
+				`"range":{"filename":"main.tf","start":{"line":2,"column":3,"byte":10},"end":{"line":2,"column":6,"byte":13}}}]}`,
+			want: &ValidateResult{
+				Valid:      false,
+				ErrorCount: 1,
+				Diagnostics: []*Diagnostic{
+					{
+						Severity: DiagnosticSeverityError,
+						Summary:  "Unsupported argument",
+						Detail:   `An argument named "foo" is not expected here.`,
+						Range: &DiagnosticRange{
+							Filename: "main.tf",
+							Start:    DiagnosticPos{Line: 2, Column: 3, Byte: 10},
+							End:      DiagnosticPos{Line: 2, Column: 6, Byte: 13},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:    "invalid json",
+			input:   `Error: no configuration`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseValidateResult([]byte(tt.input))
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}